@@ -0,0 +1,162 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// AgentJNLP is the launch information Jenkins publishes for an inbound (JNLP) agent.
+type AgentJNLP struct {
+	Secret      string
+	Name        string
+	URL         string
+	WorkDir     string
+	InternalDir string
+	Tunnel      string
+	WebSocket   bool
+	// Arguments are all arguments of the JNLP file, in order.
+	Arguments []string
+}
+
+type jnlpFile struct {
+	Arguments []string `xml:"application-desc>argument"`
+}
+
+func parseAgentJNLP(data string) (*AgentJNLP, error) {
+	var file jnlpFile
+	if err := unmarshalXML(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Arguments) < 2 {
+		return nil, errors.New("JNLP file contains no agent secret")
+	}
+	agent := &AgentJNLP{Secret: file.Arguments[0], Name: file.Arguments[1], Arguments: file.Arguments}
+	for i := 2; i < len(file.Arguments); i++ {
+		value := ""
+		if i+1 < len(file.Arguments) {
+			value = file.Arguments[i+1]
+		}
+		switch file.Arguments[i] {
+		case "-url":
+			agent.URL = value
+		case "-workDir":
+			agent.WorkDir = value
+		case "-internalDir":
+			agent.InternalDir = value
+		case "-tunnel":
+			agent.Tunnel = value
+		case "-webSocket":
+			agent.WebSocket = true
+			continue
+		default:
+			continue
+		}
+		i++
+	}
+	return agent, nil
+}
+
+// GetAgentJNLP reads the JNLP file of an inbound agent, which holds the secret needed to connect.
+// The caller needs the Agent/Connect permission.
+func (n *Node) GetAgentJNLP() (*AgentJNLP, error) {
+	isJnlp, err := n.IsJnlpAgent()
+	if err != nil {
+		return nil, err
+	}
+	if !isJnlp {
+		return nil, errors.New("Node " + n.GetName() + " is not an inbound (JNLP) agent")
+	}
+
+	var data string
+	// jenkins-agent.jnlp replaced slave-agent.jnlp in Jenkins 2.x, older versions only know the latter
+	for _, file := range []string{"/jenkins-agent.jnlp", "/slave-agent.jnlp"} {
+		resp, err := n.Client.Requester.Get(n.Base+file, &data, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == 200 {
+			agent, err := parseAgentJNLP(data)
+			if err != nil {
+				return nil, err
+			}
+			if agent.URL == "" {
+				agent.URL = n.Client.Requester.Base + "/"
+			}
+			return agent, nil
+		}
+		if resp.StatusCode != 404 {
+			return nil, errors.New(strconv.Itoa(resp.StatusCode))
+		}
+	}
+	return nil, errors.New("No JNLP file found for node " + n.GetName())
+}
+
+// GetAgentSecret returns the secret an inbound agent uses to connect to Jenkins.
+func (n *Node) GetAgentSecret() (string, error) {
+	agent, err := n.GetAgentJNLP()
+	if err != nil {
+		return "", err
+	}
+	return agent.Secret, nil
+}
+
+// Command returns the arguments to start the agent with the given agent.jar,
+// which can be downloaded from <jenkins>/jnlpJars/agent.jar.
+func (a *AgentJNLP) Command(agentJar string) []string {
+	cmd := []string{"java", "-jar", agentJar, "-url", a.URL, "-secret", a.Secret, "-name", a.Name}
+	if a.WorkDir != "" {
+		cmd = append(cmd, "-workDir", a.WorkDir)
+	}
+	if a.Tunnel != "" {
+		cmd = append(cmd, "-tunnel", a.Tunnel)
+	}
+	if a.WebSocket {
+		cmd = append(cmd, "-webSocket")
+	}
+	return cmd
+}
+
+// CommandLine returns Command quoted for a POSIX shell.
+func (a *AgentJNLP) CommandLine(agentJar string) string {
+	args := a.Command(agentJar)
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`&|;<>()*?[]{}~!#") {
+			args[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// DockerEnv returns the environment variables of the jenkins/inbound-agent docker image.
+func (a *AgentJNLP) DockerEnv() map[string]string {
+	env := map[string]string{
+		"JENKINS_URL":        a.URL,
+		"JENKINS_SECRET":     a.Secret,
+		"JENKINS_AGENT_NAME": a.Name,
+	}
+	if a.WorkDir != "" {
+		env["JENKINS_AGENT_WORKDIR"] = a.WorkDir
+	}
+	if a.Tunnel != "" {
+		env["JENKINS_TUNNEL"] = a.Tunnel
+	}
+	if a.WebSocket {
+		env["JENKINS_WEB_SOCKET"] = "true"
+	}
+	return env
+}
@@ -20,6 +20,7 @@ import (
 	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -246,8 +247,18 @@ func (b *Build) GetInjectedEnvVars() (map[string]string, error) {
 	return envVars.EnvMap, nil
 }
 
+// GetDownstreamBuilds returns, for each downstream project of the job, the first build triggered by this build.
+// It fetches every build of the downstream projects, Trace is much cheaper and also follows Pipeline build steps.
+// Builds are matched by the upstream causes, builds of deleted or renamed jobs are skipped.
 func (b *Build) GetDownstreamBuilds() ([]*Build, error) {
 	result := make([]*Build, 0)
+	name, number, ok := parseBuildEndpoint(b.Client.endpointOf(b.GetUrl()))
+	if !ok {
+		name, number, ok = parseBuildEndpoint(b.Base)
+	}
+	if !ok {
+		return nil, errors.New("Unable to get the job of build " + b.Base)
+	}
 	downstreamJobs, err := b.Job.GetDownstreamJobs()
 	if err != nil {
 		return nil, err
@@ -262,9 +273,8 @@ func (b *Build) GetDownstreamBuilds() ([]*Build, error) {
 			if err != nil {
 				return nil, err
 			}
-			upstreamBuild, _ := build.GetUpstreamBuild()
-			// cannot compare only id, it can be from different job
-			if b.GetUrl() == upstreamBuild.GetUrl() {
+			// cannot compare only the number, it can be from a different job
+			if build.triggeredBy(name, number) {
 				result = append(result, build)
 				break
 			}
@@ -273,6 +283,21 @@ func (b *Build) GetDownstreamBuilds() ([]*Build, error) {
 	return result, nil
 }
 
+// triggeredBy tells whether one of the causes of the polled build is the build number
+// of the job with the full name job.
+func (b *Build) triggeredBy(job string, number int64) bool {
+	for _, a := range b.Raw.Actions {
+		for _, cause := range a.Causes {
+			project, _ := cause["upstreamProject"].(string)
+			build, _ := cause["upstreamBuild"].(float64)
+			if project == job && int64(build) == number {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (b *Build) GetDownstreamJobNames() []string {
 	result := make([]string, 0)
 	downstreamJobs := b.Job.GetDownstreamJobsMetadata()
@@ -305,7 +330,9 @@ func (b *Build) GetUpstreamJob() (*Job, error) {
 	}
 	if len(causes) > 0 {
 		if job, ok := causes[0]["upstreamProject"]; ok {
-			return b.Client.GetJob(job.(string))
+			// the full name of a job in a folder is folder/job
+			names := strings.Split(job.(string), "/")
+			return b.Client.GetJob(names[len(names)-1], names[:len(names)-1]...)
 		}
 	}
 	return nil, errors.New("Unable to get Upstream Job")
@@ -343,6 +370,7 @@ func (b *Build) GetUpstreamBuild() (*Build, error) {
 	return nil, errors.New("Build not found")
 }
 
+// Returns the runs of a matrix build, each run is polled in parallel.
 func (b *Build) GetMatrixRuns() ([]*Build, error) {
 	_, err := b.Poll(0)
 	if err != nil {
@@ -352,11 +380,19 @@ func (b *Build) GetMatrixRuns() ([]*Build, error) {
 	result := make([]*Build, len(b.Raw.Runs))
 	r, _ := regexp.Compile(`job/(.*?)/(.*?)/(\d+)/`)
 
+	urls := make([]string, len(runs))
 	for i, run := range runs {
+		urls[i] = run.URL
 		result[i] = &Build{Client: b.Client, Job: b.Job, Raw: new(BuildResponse), Depth: 1, Base: "/" + r.FindString(run.URL)}
-		result[i].Poll()
 	}
-	return result, nil
+	err = b.Client.fetchAll(urls, func(i int) error {
+		if _, err := result[i].Poll(); err != nil {
+			result[i] = nil
+			return err
+		}
+		return nil
+	})
+	return result, err
 }
 
 func (b *Build) GetResultSet() (*TestResult, error) {
@@ -426,6 +462,37 @@ func (b *Build) IsRunning() bool {
 	return b.Raw.Building
 }
 
+// KeepForever marks the build to be kept forever, protecting it from the build discarder,
+// or removes the mark again.
+func (b *Build) KeepForever(keep bool) error {
+	if _, err := b.Poll(Fields("keepLog")); err != nil {
+		return err
+	}
+	if b.Raw.KeepLog == keep {
+		return nil
+	}
+	resp, err := b.Client.Requester.Post(b.Base+"/toggleLogKeep", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	b.Raw.KeepLog = keep
+	return nil
+}
+
+func (b *Build) Delete() (bool, error) {
+	resp, err := b.Client.Requester.Post(b.Base+"/doDelete", nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != 200 {
+		return false, errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	return true, nil
+}
+
 func (b *Build) SetDescription(description string) error {
 	data := url.Values{}
 	data.Set("description", description)
@@ -433,10 +500,12 @@ func (b *Build) SetDescription(description string) error {
 	return err
 }
 
-// Poll for current data. Optional parameter - depth.
+// Poll for current data. Optional parameters - depth and tree fields.
+// When tree fields are given only those are fetched, e.g. b.Poll(Fields("result", "building")).
 // More about depth here: https://wiki.jenkins-ci.org/display/JENKINS/Remote+access+API
 func (b *Build) Poll(options ...interface{}) (int, error) {
 	depth := "-1"
+	var tree []TreeField
 
 	for _, o := range options {
 		switch v := o.(type) {
@@ -446,6 +515,8 @@ func (b *Build) Poll(options ...interface{}) (int, error) {
 			depth = strconv.Itoa(v)
 		case int64:
 			depth = strconv.FormatInt(v, 10)
+		case TreeField:
+			tree = append(tree, v)
 		}
 	}
 	if depth == "-1" {
@@ -455,6 +526,9 @@ func (b *Build) Poll(options ...interface{}) (int, error) {
 	qr := map[string]string{
 		"depth": depth,
 	}
+	if len(tree) > 0 {
+		qr["tree"] = TreeQuery(tree...)
+	}
 	response, err := b.Client.Requester.GetJSON(b.Base, b.Raw, qr)
 	if err != nil {
 		return 0, err
@@ -1,109 +1,202 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
 package gojenkins
 
 import (
-	"io"
-	"strconv"
-	"strings"
-
-	"golang.org/x/net/html"
+	"fmt"
+	"time"
 )
 
-// Parse jenkins ajax response in order find the current jenkins build history
-func parseBuildHistory(d io.Reader) []*History {
-	z := html.NewTokenizer(d)
-	depth := 0
-	buildRowCellDepth := -1
-	builds := make([]*History, 0)
-	var curBuild *History
-	for {
-		tt := z.Next()
-		switch tt {
-		case html.ErrorToken:
-			if z.Err() == io.EOF {
-				return builds
-			}
-		case html.SelfClosingTagToken:
-			tn, hasAttr := z.TagName()
-			// fmt.Println("START__", string(tn), hasAttr)
-			if hasAttr {
-				a := attr(z)
-				// <img src="/static/f2881562/images/16x16/red.png" alt="Failed &gt; Console Output" tooltip="Failed &gt; Console Output" style="width: 16px; height: 16px; " class="icon-red icon-sm" />
-				if string(tn) == "img" {
-					if hasCSSClass(a, "icon-sm") && buildRowCellDepth > -1 {
-						if alt, found := a["alt"]; found {
-							curBuild.BuildStatus = strings.Fields(alt)[0]
-						}
-					}
-				}
-			}
-		case html.StartTagToken:
-			depth++
-			tn, hasAttr := z.TagName()
-			// fmt.Println("START__", string(tn), hasAttr)
-			if hasAttr {
-				a := attr(z)
-				// <td class="build-row-cell">
-				if string(tn) == "td" {
-					if hasCSSClass(a, "build-row-cell") {
-						buildRowCellDepth = depth
-						curBuild = &History{}
-						builds = append(builds, curBuild)
-					}
-				}
-				// <a update-parent-class=".build-row" href="/job/appscode/job/43/job/build-binary/227/" class="tip model-link inside build-link display-name">#227</a>
-				if string(tn) == "a" {
-					if hasCSSClass(a, "build-link") && buildRowCellDepth > -1 {
-						if href, found := a["href"]; found {
-							parts := strings.Split(href, "/")
-							if num, err := strconv.Atoi(parts[len(parts)-2]); err == nil {
-								curBuild.BuildNumber = num
-							}
-						}
-					}
-				}
-				// <div time="1469024602546" class="pane build-details"> ... </div>
-				if string(tn) == "div" {
-					if hasCSSClass(a, "build-details") && buildRowCellDepth > -1 {
-						if t, found := a["time"]; found {
-							if msec, err := strconv.ParseInt(t, 10, 0); err == nil {
-								curBuild.BuildTimestamp = msec / 1000
-							}
-						}
-					}
-				}
-			}
-		case html.EndTagToken:
-			tn, _ := z.TagName()
-			if string(tn) == "td" && depth == buildRowCellDepth {
-				buildRowCellDepth = -1
-				curBuild = nil
-			}
-			depth--
+// DefaultBuildPageSize is the number of builds fetched per request when iterating the build history.
+const DefaultBuildPageSize = 100
+
+var buildSummaryFields = []TreeField{
+	Fields("number", "url", "result", "timestamp", "duration", "building", "keepLog"),
+	Tree("actions", Tree("parameters", Fields("name", "value"))),
+}
+
+type BuildParameter struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// BuildSummary is the subset of a build returned by the job's build history.
+type BuildSummary struct {
+	Number    int64  `json:"number"`
+	URL       string `json:"url"`
+	Result    string `json:"result"`
+	Timestamp int64  `json:"timestamp"`
+	Duration  int64  `json:"duration"`
+	Building  bool   `json:"building"`
+	KeepLog   bool   `json:"keepLog"`
+	Actions   []struct {
+		Parameters []BuildParameter `json:"parameters"`
+	} `json:"actions"`
+}
+
+func (b BuildSummary) GetTimestamp() time.Time {
+	return time.Unix(0, b.Timestamp*int64(time.Millisecond))
+}
+
+func (b BuildSummary) GetParameters() []BuildParameter {
+	for _, a := range b.Actions {
+		if a.Parameters != nil {
+			return a.Parameters
+		}
+	}
+	return nil
+}
+
+// GetParameter returns the value of the named build parameter formatted as a string.
+func (b BuildSummary) GetParameter(name string) (string, bool) {
+	for _, p := range b.GetParameters() {
+		if p.Name == name {
+			return fmt.Sprint(p.Value), true
 		}
 	}
+	return "", false
+}
+
+// BuildQuery selects the builds returned by Job.IterateBuilds.
+// Zero values do not filter.
+type BuildQuery struct {
+	// PageSize is the number of builds fetched per request, DefaultBuildPageSize if zero.
+	PageSize int
+	// Results keeps only builds with one of the results, e.g. STATUS_SUCCESS, RESULT_STATUS_FAILURE.
+	Results []string
+	// From and To keep only builds started within the range.
+	From time.Time
+	To   time.Time
+	// Parameters keeps only builds where every named parameter has the given value.
+	Parameters map[string]string
 }
 
-func attr(z *html.Tokenizer) map[string]string {
-	a := make(map[string]string)
-	for {
-		k, v, more := z.TagAttr()
-		if k != nil && v != nil {
-			a[string(k)] = string(v)
+func (q BuildQuery) matches(b BuildSummary) bool {
+	if len(q.Results) > 0 {
+		found := false
+		for _, r := range q.Results {
+			if b.Result == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
 		}
-		if !more {
+	}
+	started := b.GetTimestamp()
+	if !q.From.IsZero() && started.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && started.After(q.To) {
+		return false
+	}
+	for name, value := range q.Parameters {
+		if v, ok := b.GetParameter(name); !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildIterator walks the build history of a job, newest build first,
+// fetching the builds one page at a time.
+// Pages are offsets into the history, so builds started or deleted during the
+// iteration shift the pages: a build can then be returned twice or skipped.
+//
+//	it := job.IterateBuilds(gojenkins.BuildQuery{Results: []string{"FAILURE"}})
+//	for it.Next() {
+//		fmt.Println(it.Build().Number)
+//	}
+//	if err := it.Err(); err != nil { ... }
+type BuildIterator struct {
+	job   *Job
+	query BuildQuery
+	start int
+	page  []BuildSummary
+	pos   int
+	done  bool
+	err   error
+}
+
+// IterateBuilds returns an iterator over the builds of the job matching the query.
+func (j *Job) IterateBuilds(query BuildQuery) *BuildIterator {
+	if query.PageSize <= 0 {
+		query.PageSize = DefaultBuildPageSize
+	}
+	return &BuildIterator{job: j, query: query, pos: -1}
+}
+
+// NextPage fetches the next page of the history and returns the builds of it matching the query.
+// The returned slice may be empty while more pages remain; it returns nil, nil when the history is exhausted.
+func (it *BuildIterator) NextPage() ([]BuildSummary, error) {
+	if it.done || it.err != nil {
+		return nil, it.err
+	}
+	var resp struct {
+		Builds []BuildSummary `json:"allBuilds"`
+	}
+	tree := Tree("allBuilds", buildSummaryFields...).Range(it.start, it.start+it.query.PageSize)
+	_, err := it.job.Client.Requester.GetJSON(it.job.Base, &resp, treeQueryString([]TreeField{tree}))
+	if err != nil {
+		it.err = err
+		return nil, err
+	}
+	it.start += len(resp.Builds)
+	if len(resp.Builds) < it.query.PageSize {
+		it.done = true
+	}
+
+	builds := make([]BuildSummary, 0, len(resp.Builds))
+	for _, b := range resp.Builds {
+		// builds are sorted newest first, nothing older can match
+		if !it.query.From.IsZero() && b.GetTimestamp().Before(it.query.From) {
+			it.done = true
 			break
 		}
+		if it.query.matches(b) {
+			builds = append(builds, b)
+		}
 	}
-	return a
+	return builds, nil
 }
 
-func hasCSSClass(a map[string]string, className string) bool {
-	if classes, found := a["class"]; found {
-		for _, class := range strings.Fields(classes) {
-			if class == className {
-				return true
-			}
+// Next advances to the next matching build, fetching a new page when needed.
+// It returns false when the history is exhausted or an error occurred.
+func (it *BuildIterator) Next() bool {
+	it.pos++
+	for it.pos >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
 		}
+		page, err := it.NextPage()
+		if err != nil {
+			return false
+		}
+		it.page = page
+		it.pos = 0
 	}
-	return false
+	return true
+}
+
+// Build returns the current build, valid after Next returned true.
+func (it *BuildIterator) Build() BuildSummary {
+	return it.page[it.pos]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *BuildIterator) Err() error {
+	return it.err
 }
@@ -0,0 +1,309 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Classes of the causes linking a build to the build that triggered it.
+var (
+	// UPSTREAM_CAUSE is used by build triggers and the Parameterized Trigger plugin.
+	UPSTREAM_CAUSE = "hudson.model.Cause$UpstreamCause"
+	// BUILD_STEP_CAUSE is used by the build step of Pipeline.
+	BUILD_STEP_CAUSE = "org.jenkinsci.plugins.workflow.support.steps.build.BuildUpstreamCause"
+)
+
+// DefaultTraceBuilds is the number of recent builds of a job searched for builds
+// triggered by a traced build when BuildTraceOptions.Builds is not set.
+const DefaultTraceBuilds = 20
+
+// BuildTraceOptions tune TraceBuild.
+type BuildTraceOptions struct {
+	// Builds is the number of recent builds of each job searched for triggered builds.
+	Builds int
+	// Jobs are searched for triggered builds in addition to the downstream projects of each
+	// job, e.g. the jobs started by Pipeline build steps, which Jenkins does not declare.
+	// Full names, see BuildDependencyGraph to find them.
+	Jobs []string
+}
+
+// BuildTraceNode is a build of the tree returned by TraceBuild, Children are the builds it triggered.
+// Err is set when the build could not be fetched, its children are then unknown.
+type BuildTraceNode struct {
+	JobName   string
+	Number    int64
+	URL       string
+	Result    string
+	Building  bool
+	Timestamp time.Time
+	// Duration is zero while the build is running.
+	Duration time.Duration
+	// CauseClass is the class of the cause linking the build to its parent, e.g. BUILD_STEP_CAUSE.
+	CauseClass string
+	Children   []*BuildTraceNode
+	Err        error
+
+	parent *BuildTraceNode
+}
+
+// Walk calls fn for the build and all builds it triggered, depth first, with the depth of each build.
+func (n *BuildTraceNode) Walk(fn func(node *BuildTraceNode, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *BuildTraceNode) walk(fn func(node *BuildTraceNode, depth int), depth int) {
+	fn(n, depth)
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Failed returns the builds that could not be fetched.
+func (n *BuildTraceNode) Failed() []*BuildTraceNode {
+	failed := make([]*BuildTraceNode, 0)
+	n.Walk(func(node *BuildTraceNode, depth int) {
+		if node.Err != nil {
+			failed = append(failed, node)
+		}
+	})
+	return failed
+}
+
+// Done tells whether the build and all builds it triggered are finished.
+func (n *BuildTraceNode) Done() bool {
+	done := true
+	n.Walk(func(node *BuildTraceNode, depth int) {
+		done = done && !node.Building
+	})
+	return done
+}
+
+func (n *BuildTraceNode) key() string {
+	return n.JobName + "#" + strconv.FormatInt(n.Number, 10)
+}
+
+type buildCause struct {
+	Class           string `json:"_class"`
+	UpstreamProject string `json:"upstreamProject"`
+	UpstreamBuild   int64  `json:"upstreamBuild"`
+}
+
+type traceBuildResponse struct {
+	Number    int64  `json:"number"`
+	URL       string `json:"url"`
+	Result    string `json:"result"`
+	Building  bool   `json:"building"`
+	Duration  int64  `json:"duration"`
+	Timestamp int64  `json:"timestamp"`
+	Actions   []struct {
+		Causes []buildCause `json:"causes"`
+		// hudson.plugins.parameterizedtrigger.BuildInfoExporterAction
+		TriggeredBuilds []struct {
+			URL string `json:"url"`
+		} `json:"triggeredBuilds"`
+		// org.jenkinsci.plugins.workflow.support.steps.build.DownstreamBuildAction
+		DownstreamBuilds []struct {
+			JobFullName string `json:"jobFullName"`
+			BuildNumber *int64 `json:"buildNumber"`
+		} `json:"downstreamBuilds"`
+	} `json:"actions"`
+}
+
+var traceCauseFields = Tree("causes", Fields("_class", "upstreamProject", "upstreamBuild"))
+
+var traceBuildFields = []TreeField{
+	Fields("number", "url", "result", "building", "duration", "timestamp"),
+	Tree("actions", traceCauseFields,
+		Tree("triggeredBuilds", Fields("url")),
+		Tree("downstreamBuilds", Fields("jobFullName", "buildNumber"))),
+}
+
+// causedBy returns the class of the cause of the build pointing to the upstream build, empty if there is none.
+func (r *traceBuildResponse) causedBy(job string, number int64) string {
+	for _, a := range r.Actions {
+		for _, c := range a.Causes {
+			if c.UpstreamProject == job && c.UpstreamBuild == number {
+				return c.Class
+			}
+		}
+	}
+	return ""
+}
+
+type traceJob struct {
+	downstream []string
+	builds     []traceBuildResponse
+	err        error
+}
+
+func (c *Client) fetchTraceJob(name string, builds int) *traceJob {
+	var resp struct {
+		DownstreamProjects []InnerJob           `json:"downstreamProjects"`
+		Builds             []traceBuildResponse `json:"builds"`
+	}
+	tree := []TreeField{
+		Tree("downstreamProjects", Fields("url")),
+		Tree("builds", Fields("number"), Tree("actions", traceCauseFields)).Range(0, builds),
+	}
+	r, err := c.Requester.GetJSON(jobEndpoint(name), &resp, treeQueryString(tree))
+	if err == nil && r.StatusCode != 200 {
+		err = errors.New("No job " + name + ": " + strconv.Itoa(r.StatusCode))
+	}
+	if err != nil {
+		return &traceJob{err: err}
+	}
+	job := &traceJob{builds: resp.Builds}
+	for _, j := range resp.DownstreamProjects {
+		job.downstream = append(job.downstream, c.jobFullName(j.Url))
+	}
+	return job
+}
+
+func (c *Client) fetchTraceBuild(n *BuildTraceNode) (*traceBuildResponse, error) {
+	resp := new(traceBuildResponse)
+	r, err := c.Requester.GetJSON(jobEndpoint(n.JobName)+"/"+strconv.FormatInt(n.Number, 10), resp, treeQueryString(traceBuildFields))
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, errors.New("No build " + n.key() + ": " + strconv.Itoa(r.StatusCode))
+	}
+	n.URL, n.Result, n.Building = resp.URL, resp.Result, resp.Building
+	n.Timestamp = time.Unix(0, resp.Timestamp*int64(time.Millisecond))
+	n.Duration = time.Duration(resp.Duration) * time.Millisecond
+	if n.parent != nil {
+		n.CauseClass = resp.causedBy(n.parent.JobName, n.parent.Number)
+	}
+	return resp, nil
+}
+
+// TraceBuild returns the tree of builds triggered by a build, directly or through the builds
+// it triggered: builds with an upstream cause pointing to their parent, as set by build
+// triggers, Pipeline build steps and the Parameterized Trigger plugin, and the builds listed
+// by the parent itself. Triggered builds are searched in the last BuildTraceOptions.Builds
+// builds of the downstream projects of each job and of BuildTraceOptions.Jobs, with one
+// request per job and per build of the tree. Builds that could not be fetched have their Err set.
+// Example: trace, _ := jenkins.TraceBuild("release/build", 42, gojenkins.BuildTraceOptions{})
+func (c *Client) TraceBuild(jobName string, number int64, opts BuildTraceOptions) (*BuildTraceNode, error) {
+	if opts.Builds <= 0 {
+		opts.Builds = DefaultTraceBuilds
+	}
+	root := &BuildTraceNode{JobName: jobName, Number: number}
+	visited := map[string]bool{root.key(): true}
+	jobs := make(map[string]*traceJob)
+	fetchJobs := func(names []string) {
+		missing := make([]string, 0)
+		for _, name := range names {
+			if _, ok := jobs[name]; !ok {
+				jobs[name] = nil
+				missing = append(missing, name)
+			}
+		}
+		fetched := make([]*traceJob, len(missing))
+		c.fetchAll(missing, func(i int) error {
+			fetched[i] = c.fetchTraceJob(missing[i], opts.Builds)
+			return fetched[i].err
+		})
+		for i, name := range missing {
+			jobs[name] = fetched[i]
+		}
+	}
+
+	level := []*BuildTraceNode{root}
+	for len(level) > 0 {
+		keys := make([]string, len(level))
+		for i, n := range level {
+			keys[i] = n.key()
+		}
+		builds := make([]*traceBuildResponse, len(level))
+		c.fetchAll(keys, func(i int) error {
+			builds[i], level[i].Err = c.fetchTraceBuild(level[i])
+			return level[i].Err
+		})
+		if root.Err != nil {
+			return nil, root.Err
+		}
+
+		jobNames := make([]string, 0)
+		for i, n := range level {
+			if builds[i] != nil {
+				jobNames = append(jobNames, n.JobName)
+			}
+		}
+		fetchJobs(jobNames)
+		candidates := append([]string{}, opts.Jobs...)
+		for _, name := range jobNames {
+			if jobs[name].err == nil {
+				candidates = append(candidates, jobs[name].downstream...)
+			}
+		}
+		fetchJobs(candidates)
+
+		next := make([]*BuildTraceNode, 0)
+		for i, n := range level {
+			if builds[i] == nil {
+				continue
+			}
+			addChild := func(job string, number int64) {
+				child := &BuildTraceNode{JobName: job, Number: number, parent: n}
+				if visited[child.key()] {
+					return
+				}
+				visited[child.key()] = true
+				n.Children = append(n.Children, child)
+				next = append(next, child)
+			}
+			for _, a := range builds[i].Actions {
+				for _, t := range a.TriggeredBuilds {
+					if job, number, ok := parseBuildEndpoint(c.endpointOf(t.URL)); ok {
+						addChild(job, number)
+					}
+				}
+				for _, d := range a.DownstreamBuilds {
+					if d.BuildNumber != nil {
+						addChild(d.JobFullName, *d.BuildNumber)
+					}
+				}
+			}
+			searched := make(map[string]bool)
+			names := append(append([]string{}, opts.Jobs...), jobs[n.JobName].downstream...)
+			for _, name := range names {
+				if searched[name] || jobs[name].err != nil {
+					continue
+				}
+				searched[name] = true
+				for _, b := range jobs[name].builds {
+					if b.causedBy(n.JobName, n.Number) != "" {
+						addChild(name, b.Number)
+					}
+				}
+			}
+		}
+		level = next
+	}
+	return root, nil
+}
+
+// Trace returns the tree of builds triggered by the build, see Client.TraceBuild.
+func (b *Build) Trace(opts BuildTraceOptions) (*BuildTraceNode, error) {
+	job, number, ok := parseBuildEndpoint(b.Base)
+	if !ok {
+		return nil, errors.New("Unexpected build endpoint " + b.Base)
+	}
+	return b.Client.TraceBuild(job, number, opts)
+}
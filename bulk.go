@@ -0,0 +1,96 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultConcurrency is the number of parallel requests used by the bulk
+// functions (GetAllJobs, GetAllViews, ...) when Client.Concurrency is not set.
+const DefaultConcurrency = 8
+
+// ItemError is the failure of a single item of a bulk request.
+type ItemError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+// BulkError is returned by functions fetching several items at once when some of them failed.
+// The items that could be fetched are still returned, failed ones are left nil.
+type BulkError struct {
+	Errors []ItemError
+}
+
+func (e *BulkError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of the requested items failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (c *Client) concurrency() int {
+	if c.Concurrency > 0 {
+		return c.Concurrency
+	}
+	return DefaultConcurrency
+}
+
+// fetchAll calls fetch for every name using at most Client.Concurrency goroutines.
+// fetch is given the index of the item so results can be stored in order.
+// All items are attempted, errors are collected into a *BulkError.
+func (c *Client) fetchAll(names []string, fetch func(i int) error) error {
+	errs := make([]error, len(names))
+	sem := make(chan struct{}, c.concurrency())
+	var wg sync.WaitGroup
+
+	for i := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fetch(i)
+		}(i)
+	}
+	wg.Wait()
+
+	bulk := &BulkError{}
+	for i, err := range errs {
+		if err != nil {
+			bulk.Errors = append(bulk.Errors, ItemError{Index: i, Name: names[i], Err: err})
+		}
+	}
+	if len(bulk.Errors) > 0 {
+		return bulk
+	}
+	return nil
+}
+
+func innerJobNames(jobs []InnerJob) []string {
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.Name
+	}
+	return names
+}
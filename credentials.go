@@ -0,0 +1,450 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Scopes of a credential: GLOBAL credentials are available to jobs,
+// SYSTEM credentials only to Jenkins itself, e.g. to launch agents.
+const (
+	CREDENTIAL_SCOPE_GLOBAL = "GLOBAL"
+	CREDENTIAL_SCOPE_SYSTEM = "SYSTEM"
+
+	// GLOBAL_DOMAIN is the domain of credentials without restrictions.
+	GLOBAL_DOMAIN = "_"
+)
+
+// Classes of the supported credential kinds.
+var (
+	USERNAME_PASSWORD_CREDENTIALS = "com.cloudbees.plugins.credentials.impl.UsernamePasswordCredentialsImpl"
+	SECRET_TEXT_CREDENTIALS       = "org.jenkinsci.plugins.plaincredentials.impl.StringCredentialsImpl"
+	SSH_PRIVATE_KEY_CREDENTIALS   = "com.cloudbees.jenkins.plugins.sshcredentials.impl.BasicSSHUserPrivateKey"
+	CERTIFICATE_CREDENTIALS       = "com.cloudbees.plugins.credentials.impl.CertificateCredentialsImpl"
+	FILE_CREDENTIALS              = "org.jenkinsci.plugins.plaincredentials.impl.FileCredentialsImpl"
+)
+
+// Credential is one of UsernamePasswordCredentials, SecretTextCredentials,
+// SSHPrivateKeyCredentials, CertificateCredentials or FileCredentials.
+// Jenkins never returns secrets, so the secret fields of credentials read from
+// Jenkins are empty. String and GoString of the kinds redact the secrets.
+type Credential interface {
+	GetID() string
+	Class() string
+	toXML() credentialXML
+}
+
+type UsernamePasswordCredentials struct {
+	ID          string
+	Description string
+	Scope       string // CREDENTIAL_SCOPE_GLOBAL if empty
+	Username    string
+	Password    string
+}
+
+type SecretTextCredentials struct {
+	ID          string
+	Description string
+	Scope       string
+	Secret      string
+}
+
+type SSHPrivateKeyCredentials struct {
+	ID          string
+	Description string
+	Scope       string
+	Username    string
+	PrivateKey  string // PEM encoded
+	Passphrase  string
+}
+
+type CertificateCredentials struct {
+	ID          string
+	Description string
+	Scope       string
+	KeyStore    []byte // PKCS#12
+	Password    string
+}
+
+type FileCredentials struct {
+	ID          string
+	Description string
+	Scope       string
+	FileName    string
+	Content     []byte
+}
+
+func (c UsernamePasswordCredentials) GetID() string { return c.ID }
+func (c SecretTextCredentials) GetID() string       { return c.ID }
+func (c SSHPrivateKeyCredentials) GetID() string    { return c.ID }
+func (c CertificateCredentials) GetID() string      { return c.ID }
+func (c FileCredentials) GetID() string             { return c.ID }
+
+func (c UsernamePasswordCredentials) Class() string { return USERNAME_PASSWORD_CREDENTIALS }
+func (c SecretTextCredentials) Class() string       { return SECRET_TEXT_CREDENTIALS }
+func (c SSHPrivateKeyCredentials) Class() string    { return SSH_PRIVATE_KEY_CREDENTIALS }
+func (c CertificateCredentials) Class() string      { return CERTIFICATE_CREDENTIALS }
+func (c FileCredentials) Class() string             { return FILE_CREDENTIALS }
+
+func (c UsernamePasswordCredentials) String() string {
+	return fmt.Sprintf("UsernamePasswordCredentials{ID: %q, Description: %q, Scope: %q, Username: %q, Password: %s}",
+		c.ID, c.Description, c.Scope, c.Username, redacted(c.Password != ""))
+}
+
+func (c SecretTextCredentials) String() string {
+	return fmt.Sprintf("SecretTextCredentials{ID: %q, Description: %q, Scope: %q, Secret: %s}",
+		c.ID, c.Description, c.Scope, redacted(c.Secret != ""))
+}
+
+func (c SSHPrivateKeyCredentials) String() string {
+	return fmt.Sprintf("SSHPrivateKeyCredentials{ID: %q, Description: %q, Scope: %q, Username: %q, PrivateKey: %s, Passphrase: %s}",
+		c.ID, c.Description, c.Scope, c.Username, redacted(c.PrivateKey != ""), redacted(c.Passphrase != ""))
+}
+
+func (c CertificateCredentials) String() string {
+	return fmt.Sprintf("CertificateCredentials{ID: %q, Description: %q, Scope: %q, KeyStore: %s, Password: %s}",
+		c.ID, c.Description, c.Scope, redacted(len(c.KeyStore) > 0), redacted(c.Password != ""))
+}
+
+func (c FileCredentials) String() string {
+	return fmt.Sprintf("FileCredentials{ID: %q, Description: %q, Scope: %q, FileName: %q, Content: %s}",
+		c.ID, c.Description, c.Scope, c.FileName, redacted(len(c.Content) > 0))
+}
+
+// GoString keeps the secrets out of %#v as well.
+func (c UsernamePasswordCredentials) GoString() string { return c.String() }
+func (c SecretTextCredentials) GoString() string       { return c.String() }
+func (c SSHPrivateKeyCredentials) GoString() string    { return c.String() }
+func (c CertificateCredentials) GoString() string      { return c.String() }
+func (c FileCredentials) GoString() string             { return c.String() }
+
+func redacted(set bool) string {
+	if set {
+		return "<redacted>"
+	}
+	return `""`
+}
+
+// credentialXML is the serialized form of all credential kinds,
+// only the fields of the kind named by XMLName are written.
+type credentialXML struct {
+	XMLName          xml.Name
+	Scope            string            `xml:"scope"`
+	ID               string            `xml:"id"`
+	Description      string            `xml:"description"`
+	Username         *string           `xml:"username"`
+	Password         *string           `xml:"password"`
+	Secret           *string           `xml:"secret"`
+	Passphrase       *string           `xml:"passphrase"`
+	PrivateKeySource *credentialSource `xml:"privateKeySource"`
+	KeyStoreSource   *credentialSource `xml:"keyStoreSource"`
+	FileName         *string           `xml:"fileName"`
+	SecretBytes      *string           `xml:"secretBytes"` // base64
+}
+
+type credentialSource struct {
+	Class      string  `xml:"class,attr"`
+	PrivateKey *string `xml:"privateKey"`
+	KeyStore   *string `xml:"uploadedKeystoreBytes"` // base64
+}
+
+func credentialScope(scope string) string {
+	if scope == "" {
+		return CREDENTIAL_SCOPE_GLOBAL
+	}
+	return scope
+}
+
+func (c UsernamePasswordCredentials) toXML() credentialXML {
+	return credentialXML{XMLName: xml.Name{Local: c.Class()}, Scope: credentialScope(c.Scope), ID: c.ID, Description: c.Description,
+		Username: &c.Username, Password: &c.Password}
+}
+
+func (c SecretTextCredentials) toXML() credentialXML {
+	return credentialXML{XMLName: xml.Name{Local: c.Class()}, Scope: credentialScope(c.Scope), ID: c.ID, Description: c.Description,
+		Secret: &c.Secret}
+}
+
+func (c SSHPrivateKeyCredentials) toXML() credentialXML {
+	return credentialXML{XMLName: xml.Name{Local: c.Class()}, Scope: credentialScope(c.Scope), ID: c.ID, Description: c.Description,
+		Username: &c.Username, Passphrase: &c.Passphrase,
+		PrivateKeySource: &credentialSource{Class: c.Class() + "$DirectEntryPrivateKeySource", PrivateKey: &c.PrivateKey}}
+}
+
+func (c CertificateCredentials) toXML() credentialXML {
+	return credentialXML{XMLName: xml.Name{Local: c.Class()}, Scope: credentialScope(c.Scope), ID: c.ID, Description: c.Description,
+		Password:       &c.Password,
+		KeyStoreSource: &credentialSource{Class: c.Class() + "$UploadedKeyStoreSource", KeyStore: base64String(c.KeyStore)}}
+}
+
+func (c FileCredentials) toXML() credentialXML {
+	return credentialXML{XMLName: xml.Name{Local: c.Class()}, Scope: credentialScope(c.Scope), ID: c.ID, Description: c.Description,
+		FileName: &c.FileName, SecretBytes: base64String(c.Content)}
+}
+
+func base64String(b []byte) *string {
+	s := base64.StdEncoding.EncodeToString(b)
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// parseCredential reads the config.xml of a credential. Jenkins redacts the secrets,
+// which leaves the secret fields empty.
+func parseCredential(data string) (Credential, error) {
+	var x credentialXML
+	if err := unmarshalXML(data, &x); err != nil {
+		return nil, err
+	}
+	switch x.XMLName.Local {
+	case USERNAME_PASSWORD_CREDENTIALS:
+		return UsernamePasswordCredentials{ID: x.ID, Description: x.Description, Scope: x.Scope, Username: stringValue(x.Username)}, nil
+	case SECRET_TEXT_CREDENTIALS:
+		return SecretTextCredentials{ID: x.ID, Description: x.Description, Scope: x.Scope}, nil
+	case SSH_PRIVATE_KEY_CREDENTIALS:
+		return SSHPrivateKeyCredentials{ID: x.ID, Description: x.Description, Scope: x.Scope, Username: stringValue(x.Username)}, nil
+	case CERTIFICATE_CREDENTIALS:
+		return CertificateCredentials{ID: x.ID, Description: x.Description, Scope: x.Scope}, nil
+	case FILE_CREDENTIALS:
+		return FileCredentials{ID: x.ID, Description: x.Description, Scope: x.Scope, FileName: stringValue(x.FileName)}, nil
+	}
+	return nil, errors.New("unsupported credential kind " + x.XMLName.Local)
+}
+
+// CredentialInfo describes a credential without its secrets.
+type CredentialInfo struct {
+	ID          string `json:"id"`
+	TypeName    string `json:"typeName"`
+	DisplayName string `json:"displayName"`
+	Description string `json:"description"`
+	FullName    string `json:"fullName"`
+	// Domain is the url name of the domain holding the credential.
+	Domain string `json:"-"`
+}
+
+// CredentialDomain groups credentials, GLOBAL_DOMAIN holds the credentials without restrictions.
+type CredentialDomain struct {
+	URLName     string           `json:"urlName"`
+	DisplayName string           `json:"displayName"`
+	Description string           `json:"description"`
+	Global      bool             `json:"global"`
+	Credentials []CredentialInfo `json:"credentials"`
+}
+
+var credentialInfoFields = Fields("id", "typeName", "displayName", "description", "fullName")
+
+// CredentialStore is the system credential store or the store of a folder.
+type CredentialStore struct {
+	Client *Client
+	Base   string
+}
+
+// GetSystemCredentialStore returns the store of the credentials defined on Jenkins itself.
+func (c *Client) GetSystemCredentialStore() *CredentialStore {
+	return &CredentialStore{Client: c, Base: "/credentials/store/system"}
+}
+
+// GetCredentialStore returns the store of the credentials defined on the folder.
+func (f *Folder) GetCredentialStore() *CredentialStore {
+	return &CredentialStore{Client: f.Client, Base: f.Base + "/credentials/store/folder"}
+}
+
+func (s *CredentialStore) domainBase(domain string) string {
+	if domain == "" {
+		domain = GLOBAL_DOMAIN
+	}
+	return s.Base + "/domain/" + url.PathEscape(domain)
+}
+
+func (s *CredentialStore) credentialBase(domain, id string) string {
+	return s.domainBase(domain) + "/credential/" + url.PathEscape(id)
+}
+
+// GetDomains returns the domains of the store with their credentials, sorted by url name.
+func (s *CredentialStore) GetDomains() ([]CredentialDomain, error) {
+	var resp struct {
+		Domains map[string]CredentialDomain `json:"domains"`
+	}
+	tree := []TreeField{Tree("domains", Fields("urlName", "displayName", "description", "global"), Tree("credentials", credentialInfoFields))}
+	r, err := s.Client.Requester.GetJSON(s.Base, &resp, treeQueryString(tree))
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(r.StatusCode))
+	}
+	domains := make([]CredentialDomain, 0, len(resp.Domains))
+	for name, d := range resp.Domains {
+		if d.URLName == "" {
+			d.URLName = name
+		}
+		for i := range d.Credentials {
+			d.Credentials[i].Domain = d.URLName
+		}
+		domains = append(domains, d)
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].URLName < domains[j].URLName })
+	return domains, nil
+}
+
+// List returns the credentials of a domain, "" is GLOBAL_DOMAIN.
+func (s *CredentialStore) List(domain string) ([]CredentialInfo, error) {
+	var resp struct {
+		URLName     string           `json:"urlName"`
+		Credentials []CredentialInfo `json:"credentials"`
+	}
+	tree := []TreeField{Fields("urlName"), Tree("credentials", credentialInfoFields)}
+	r, err := s.Client.Requester.GetJSON(s.domainBase(domain), &resp, treeQueryString(tree))
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(r.StatusCode))
+	}
+	for i := range resp.Credentials {
+		resp.Credentials[i].Domain = resp.URLName
+	}
+	return resp.Credentials, nil
+}
+
+// ListAll returns the credentials of all domains of the store.
+func (s *CredentialStore) ListAll() ([]CredentialInfo, error) {
+	domains, err := s.GetDomains()
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]CredentialInfo, 0)
+	for _, d := range domains {
+		credentials = append(credentials, d.Credentials...)
+	}
+	return credentials, nil
+}
+
+// Find looks up a credential by ID in all domains of the store, nil if there is none.
+func (s *CredentialStore) Find(id string) (*CredentialInfo, error) {
+	credentials, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range credentials {
+		if credentials[i].ID == id {
+			return &credentials[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Get returns the typed credential with its secrets left empty.
+func (s *CredentialStore) Get(domain, id string) (Credential, error) {
+	var data string
+	r, err := s.Client.Requester.GetXML(s.credentialBase(domain, id)+"/config.xml", &data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("No credential with id %s in domain %s: %d", id, domain, r.StatusCode)
+	}
+	return parseCredential(data)
+}
+
+// Create adds a credential to a domain, "" is GLOBAL_DOMAIN.
+func (s *CredentialStore) Create(domain string, credential Credential) error {
+	data, err := xml.Marshal(credential.toXML())
+	if err != nil {
+		return err
+	}
+	r, err := s.Client.Requester.PostXML(s.domainBase(domain)+"/createCredentials", string(data), nil, nil)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != 200 {
+		return fmt.Errorf("Could not create credential %s: %d", credential.GetID(), r.StatusCode)
+	}
+	return nil
+}
+
+// Update replaces the credential with the same ID. All fields are replaced,
+// so the secrets have to be set even if they do not change.
+func (s *CredentialStore) Update(domain string, credential Credential) error {
+	data, err := xml.Marshal(credential.toXML())
+	if err != nil {
+		return err
+	}
+	r, err := s.Client.Requester.PostXML(s.credentialBase(domain, credential.GetID())+"/config.xml", string(data), nil, nil)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != 200 {
+		return fmt.Errorf("Could not update credential %s: %d", credential.GetID(), r.StatusCode)
+	}
+	return nil
+}
+
+func (s *CredentialStore) Delete(domain, id string) error {
+	r, err := s.Client.Requester.Post(s.credentialBase(domain, id)+"/doDelete", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != 200 {
+		return fmt.Errorf("Could not delete credential %s: %d", id, r.StatusCode)
+	}
+	return nil
+}
+
+// CreateDomain adds a domain without specifications to the store.
+func (s *CredentialStore) CreateDomain(name, description string) error {
+	domain := struct {
+		XMLName     xml.Name `xml:"com.cloudbees.plugins.credentials.domains.Domain"`
+		Name        string   `xml:"name"`
+		Description string   `xml:"description"`
+	}{Name: name, Description: description}
+	data, err := xml.Marshal(domain)
+	if err != nil {
+		return err
+	}
+	r, err := s.Client.Requester.PostXML(s.Base+"/createDomain", string(data), nil, nil)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != 200 {
+		return fmt.Errorf("Could not create credential domain %s: %d", name, r.StatusCode)
+	}
+	return nil
+}
+
+// FindCredential looks up a credential by ID in the system store, e.g. to validate
+// the credentialsId of an agent before CreateNode. Returns an error if there is none.
+func (c *Client) FindCredential(id string) (*CredentialInfo, error) {
+	info, err := c.GetSystemCredentialStore().Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errors.New("No credential with id " + id)
+	}
+	return info, nil
+}
@@ -14,31 +14,145 @@
 
 package gojenkins
 
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Executor struct {
 	Raw    *ExecutorResponse
 	Client *Client
 }
 type ViewData struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Class string `json:"_class"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`
 }
 type ExecutorResponse struct {
-	AssignedLabels  []struct{}  `json:"assignedLabels"`
-	Description     interface{} `json:"description"`
-	Jobs            []InnerJob  `json:"jobs"`
-	Mode            string      `json:"mode"`
-	NodeDescription string      `json:"nodeDescription"`
-	NodeName        string      `json:"nodeName"`
-	NumExecutors    int64       `json:"numExecutors"`
-	OverallLoad     struct{}    `json:"overallLoad"`
+	AssignedLabels  []struct{}     `json:"assignedLabels"`
+	Description     interface{}    `json:"description"`
+	Jobs            []InnerJob     `json:"jobs"`
+	Mode            string         `json:"mode"`
+	NodeDescription string         `json:"nodeDescription"`
+	NodeName        string         `json:"nodeName"`
+	NumExecutors    int64          `json:"numExecutors"`
+	OverallLoad     LoadStatistics `json:"overallLoad"`
 	PrimaryView     struct {
 		Name string `json:"name"`
 		URL  string `json:"url"`
 	} `json:"primaryView"`
-	QuietingDown   bool       `json:"quietingDown"`
-	SlaveAgentPort int64      `json:"slaveAgentPort"`
-	UnlabeledLoad  struct{}   `json:"unlabeledLoad"`
-	UseCrumbs      bool       `json:"useCrumbs"`
-	UseSecurity    bool       `json:"useSecurity"`
-	Views          []ViewData `json:"views"`
+	QuietingDown   bool           `json:"quietingDown"`
+	SlaveAgentPort int64          `json:"slaveAgentPort"`
+	UnlabeledLoad  LoadStatistics `json:"unlabeledLoad"`
+	UseCrumbs      bool           `json:"useCrumbs"`
+	UseSecurity    bool           `json:"useSecurity"`
+	Views          []ViewData     `json:"views"`
+}
+
+// RunningBuild is a build occupying an executor.
+type RunningBuild struct {
+	Node string
+	// Executor is the number of the executor on the node, -1 for the one-off
+	// (flyweight) executors running Pipeline and matrix parent builds.
+	Executor          int
+	JobName           string
+	BuildNumber       int64
+	DisplayName       string
+	URL               string
+	StartTime         time.Time
+	Elapsed           time.Duration
+	EstimatedDuration time.Duration // -1 when Jenkins has no estimate
+	Progress          int           // percent of the estimate, -1 when unknown
+	LikelyStuck       bool
+}
+
+// OverEstimate reports whether the build runs longer than Jenkins estimated.
+func (r RunningBuild) OverEstimate() bool {
+	return r.EstimatedDuration > 0 && r.Elapsed > r.EstimatedDuration
+}
+
+var buildPathRe = regexp.MustCompile(`^((?:/job/[^/]+)+)(/[^/]+=[^/]*)?/(\d+)/?$`)
+
+// parseBuildEndpoint splits /job/folder/job/name/12 into the full job name folder/name and 12.
+// The run of a matrix configuration, /job/name/label=linux/12, belongs to the job name/label=linux.
+func parseBuildEndpoint(endpoint string) (string, int64, bool) {
+	m := buildPathRe.FindStringSubmatch(endpoint)
+	if m == nil {
+		return "", 0, false
+	}
+	number, err := strconv.ParseInt(m[3], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return strings.Replace(strings.TrimPrefix(m[1], "/job/"), "/job/", "/", -1) + m[2], number, true
+}
+
+func (c *Client) runningBuilds(node *NodeResponse, now time.Time) []RunningBuild {
+	builds := make([]RunningBuild, 0)
+	add := func(executors []NodeExecutor, oneOff bool) {
+		for _, e := range executors {
+			exe := e.CurrentExecutable
+			if exe.URL == "" {
+				continue
+			}
+			r := RunningBuild{
+				Node:              node.DisplayName,
+				Executor:          e.Number,
+				DisplayName:       exe.FullDisplayName,
+				URL:               exe.URL,
+				EstimatedDuration: -1,
+				Progress:          e.Progress,
+				LikelyStuck:       e.LikelyStuck,
+			}
+			if oneOff {
+				r.Executor = -1
+			}
+			if name, number, ok := parseBuildEndpoint(c.endpointOf(exe.URL)); ok {
+				r.JobName, r.BuildNumber = name, number
+			}
+			if exe.Timestamp > 0 {
+				r.StartTime = time.Unix(0, exe.Timestamp*int64(time.Millisecond))
+				r.Elapsed = now.Sub(r.StartTime)
+			}
+			if exe.EstimatedDuration > 0 {
+				r.EstimatedDuration = time.Duration(exe.EstimatedDuration) * time.Millisecond
+			}
+			builds = append(builds, r)
+		}
+	}
+	add(node.Executors, false)
+	add(node.OneOffExecutors, true)
+	return builds
+}
+
+// GetRunningBuilds returns the builds currently running on any node, in a single request.
+// Example: find builds running over their estimate
+//
+//	builds, _ := jenkins.GetRunningBuilds()
+//	for _, b := range builds {
+//		if b.OverEstimate() { fmt.Println(b.JobName, b.BuildNumber, b.Node, b.Elapsed) }
+//	}
+func (c *Client) GetRunningBuilds() ([]RunningBuild, error) {
+	computers := new(Computers)
+	tree := Tree("computer", Fields("displayName"), Tree("executors", executorFields...), Tree("oneOffExecutors", executorFields...))
+	_, err := c.Requester.GetJSON("/computer", computers, treeQueryString([]TreeField{tree}))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	builds := make([]RunningBuild, 0)
+	for _, node := range computers.Computers {
+		builds = append(builds, c.runningBuilds(node, now)...)
+	}
+	return builds, nil
+}
+
+// GetRunningBuilds returns the builds currently running on the node.
+func (n *Node) GetRunningBuilds() ([]RunningBuild, error) {
+	if err := n.pollState(); err != nil {
+		return nil, err
+	}
+	return n.Client.runningBuilds(n.Raw, time.Now()), nil
 }
@@ -69,7 +69,12 @@ func (f *Folder) Create(name string) (*Folder, error) {
 }
 
 func (f *Folder) Poll() (int, error) {
-	response, err := f.Client.Requester.GetJSON(f.Base, f.Raw, nil)
+	return f.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (f *Folder) PollTree(tree ...TreeField) (int, error) {
+	response, err := f.Client.Requester.GetJSON(f.Base, f.Raw, treeQueryString(tree))
 	if err != nil {
 		return 0, err
 	}
@@ -17,10 +17,13 @@ package gojenkins
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -40,6 +43,15 @@ type Client struct {
 	Version    string
 	Raw        *ExecutorResponse
 	Requester  *Requester
+	// Concurrency limits the number of parallel requests made by bulk functions
+	// such as GetAllJobs. DefaultConcurrency is used when it is zero.
+	Concurrency int
+	// PollInterval is the time between two checks of functions waiting for Jenkins,
+	// such as Node.Drain or WaitUntilReady. Each function has its own default when it is zero.
+	PollInterval time.Duration
+
+	// restartSession is the X-Jenkins-Session before SafeRestart or Restart, see WaitUntilReady.
+	restartSession string
 }
 
 // Loggers
@@ -131,12 +143,18 @@ func (c *Client) GetBuild(jobName string, number int64) (*Build, error) {
 	return build, nil
 }
 
-func (c *Client) GetAllNodes() ([]*Node, error) {
+// Get all nodes. Optional tree fields select which fields of each node are fetched,
+// by default the nodes are fetched with depth 1.
+func (c *Client) GetAllNodes(tree ...TreeField) ([]*Node, error) {
 	computers := new(Computers)
 
 	qr := map[string]string{
 		"depth": "1",
 	}
+	if len(tree) > 0 {
+		// the display name is needed to address the node
+		qr = map[string]string{"tree": Tree("computer", append([]TreeField{Fields("displayName")}, tree...)...).String()}
+	}
 
 	_, err := c.Requester.GetJSON("/computer", computers, qr)
 	if err != nil {
@@ -177,7 +195,8 @@ func (c *Client) GetAllJobNames() ([]InnerJob, error) {
 }
 
 // Get All Possible Job Objects.
-// Each job will be queried.
+// Each job will be queried, using up to Client.Concurrency parallel requests.
+// Jobs that could not be fetched are nil and reported in the returned *BulkError.
 func (c *Client) GetAllJobs() ([]*Job, error) {
 	exec := Executor{Raw: new(ExecutorResponse), Client: c}
 	_, err := c.Requester.GetJSON("/", exec.Raw, nil)
@@ -187,20 +206,36 @@ func (c *Client) GetAllJobs() ([]*Job, error) {
 	}
 
 	jobs := make([]*Job, len(exec.Raw.Jobs))
-	for i, job := range exec.Raw.Jobs {
-		ji, err := c.GetJob(job.Name)
-		if err != nil {
-			return nil, err
-		}
+	err = c.fetchAll(innerJobNames(exec.Raw.Jobs), func(i int) error {
+		ji, err := c.GetJob(exec.Raw.Jobs[i].Name)
 		jobs[i] = ji
+		return err
+	})
+	return jobs, err
+}
+
+// Get summary data of all jobs in a single request.
+// Only the requested fields of each job are filled, e.g. GetAllJobSummaries("name", "color", "lastBuild[number,result]")
+// Nested fields can also be built with Tree, e.g. Tree("lastBuild", Fields("number", "result")).String().
+// Without fields name, url, color, description and buildable are returned.
+func (c *Client) GetAllJobSummaries(fields ...string) ([]JobResponse, error) {
+	if len(fields) == 0 {
+		fields = []string{"name", "url", "color", "description", "buildable"}
+	}
+	var resp struct {
+		Jobs []JobResponse `json:"jobs"`
 	}
-	return jobs, nil
+	_, err := c.Requester.GetJSON("/", &resp, map[string]string{"tree": "jobs[" + strings.Join(fields, ",") + "]"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
 }
 
-// Returns a Queue
-func (c *Client) GetQueue() (*Queue, error) {
+// Returns a Queue, optionally with only the selected tree fields.
+func (c *Client) GetQueue(tree ...TreeField) (*Queue, error) {
 	q := &Queue{Client: c, Raw: new(queueResponse), Base: c.GetQueueUrl()}
-	_, err := q.Poll()
+	_, err := q.PollTree(tree...)
 	if err != nil {
 		return nil, err
 	}
@@ -211,6 +246,21 @@ func (c *Client) GetQueueUrl() string {
 	return "/queue"
 }
 
+// GetQueueItem returns a queue item by its id, e.g. the id returned by BuildJob.
+// Jenkins keeps items that left the queue for a few minutes, their Executable
+// points at the started build unless the item was cancelled.
+func (c *Client) GetQueueItem(id int64) (*Task, error) {
+	t := &Task{Jenkins: c, Raw: new(taskResponse)}
+	resp, err := c.Requester.GetJSON(c.GetQueueUrl()+"/item/"+strconv.FormatInt(id, 10), t.Raw, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("No queue item with id %d", id)
+	}
+	return t, nil
+}
+
 // Get Artifact data by Hash
 func (c *Client) GetArtifactData(id string) (*FingerPrintResponse, error) {
 	fp := FingerPrint{Client: c, Base: "/fingerprint/", Id: id, Raw: new(FingerPrintResponse)}
@@ -218,7 +268,12 @@ func (c *Client) GetArtifactData(id string) (*FingerPrintResponse, error) {
 }
 
 func (c *Client) Poll() (int, error) {
-	resp, err := c.Requester.GetJSON("/", c.Raw, nil)
+	return c.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (c *Client) PollTree(tree ...TreeField) (int, error) {
+	resp, err := c.Requester.GetJSON("/", c.Raw, treeQueryString(tree))
 	if err != nil {
 		return 0, err
 	}
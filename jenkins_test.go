@@ -1,8 +1,17 @@
 package gojenkins
 
 import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io/ioutil"
-	"os"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -71,13 +80,62 @@ func TestCreateBuilds(t *testing.T) {
 	}
 }
 
-func TestParseBuildHistory(t *testing.T) {
-	r, err := os.Open("_tests/build_history.txt")
-	if err != nil {
-		panic(err)
+func TestIterateBuilds(t *testing.T) {
+	builds := []string{
+		`{"number":5,"result":"FAILURE","timestamp":5000,"actions":[{"parameters":[{"name":"env","value":"prod"}]}]}`,
+		`{"number":4,"result":"SUCCESS","timestamp":4000,"actions":[{"parameters":[{"name":"env","value":"prod"}]}]}`,
+		`{"number":3,"result":"FAILURE","timestamp":3000,"actions":[{"parameters":[{"name":"env","value":"dev"}]}]}`,
+		`{"number":2,"result":"FAILURE","timestamp":2000,"actions":[{"parameters":[{"name":"env","value":"prod"}]}]}`,
+		`{"number":1,"result":"SUCCESS","timestamp":1000,"actions":[]}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var start, end int
+		fmt.Sscanf(r.URL.Query().Get("tree")[strings.LastIndex(r.URL.Query().Get("tree"), "{"):], "{%d,%d}", &start, &end)
+		if end > len(builds) {
+			end = len(builds)
+		}
+		if start > end {
+			start = end
+		}
+		fmt.Fprintf(w, `{"allBuilds":[%s]}`, strings.Join(builds[start:end], ","))
+	}))
+	defer server.Close()
+
+	job := &Job{Client: CreateJenkins(nil, server.URL), Raw: new(JobResponse), Base: "/job/test"}
+	it := job.IterateBuilds(BuildQuery{
+		PageSize:   2,
+		Results:    []string{RESULT_STATUS_FAILURE},
+		Parameters: map[string]string{"env": "prod"},
+	})
+	numbers := make([]int64, 0)
+	for it.Next() {
+		numbers = append(numbers, it.Build().Number)
 	}
-	history := parseBuildHistory(r)
-	assert.True(t, len(history) == 3)
+	assert.Nil(t, it.Err())
+	assert.Equal(t, []int64{5, 2}, numbers)
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	it = job.IterateBuilds(BuildQuery{PageSize: 2, From: time.Unix(3, 0)})
+	numbers = make([]int64, 0)
+	for it.Next() {
+		numbers = append(numbers, it.Build().Number)
+	}
+	assert.Equal(t, []int64{5, 4, 3}, numbers)
+	assert.Equal(t, 2, requests)
+
+	history, err := job.History()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(history))
+	assert.Equal(t, "Failed", history[0].BuildStatus)
+	assert.Equal(t, "Success", history[1].BuildStatus)
+	assert.Equal(t, int64(5), history[0].BuildTimestamp)
+	ids, err := job.GetAllBuildIds()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(ids))
+	assert.Equal(t, int64(1), ids[4].Number)
 }
 
 func TestCreateViews(t *testing.T) {
@@ -249,6 +307,268 @@ func TestConcurrentRequests(t *testing.T) {
 	}
 }
 
+func TestFetchAllKeepsOrderAndCollectsErrors(t *testing.T) {
+	c := &Client{Concurrency: 2}
+	names := []string{"a", "b", "c", "d", "e"}
+	results := make([]string, len(names))
+	err := c.fetchAll(names, func(i int) error {
+		if names[i] == "c" {
+			return errors.New("not found")
+		}
+		results[i] = names[i]
+		return nil
+	})
+	assert.Equal(t, []string{"a", "b", "", "d", "e"}, results)
+	bulk, ok := err.(*BulkError)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(bulk.Errors))
+	assert.Equal(t, 2, bulk.Errors[0].Index)
+	assert.Equal(t, "c", bulk.Errors[0].Name)
+}
+
+func TestGetInnerJobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/job/folder/api/json":
+			fmt.Fprint(w, `{"name":"folder","jobs":[{"name":"a"},{"name":"b"},{"name":"gone"}]}`)
+		case "/job/folder/job/a/api/json", "/job/folder/job/b/api/json":
+			fmt.Fprintf(w, `{"name":%q}`, strings.Split(r.URL.Path, "/")[4])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	folder, err := CreateJenkins(nil, server.URL).GetJob("folder")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "gone"}, innerJobNames(folder.GetInnerJobsMetadata()))
+	assert.Equal(t, folder.Raw.Jobs, folder.Raw.SubJobs)
+	jobs, err := folder.GetInnerJobs()
+	assert.Equal(t, 3, len(jobs))
+	assert.Equal(t, "a", jobs[0].GetName())
+	assert.Equal(t, "b", jobs[1].GetName())
+	assert.Nil(t, jobs[2])
+	bulk, ok := err.(*BulkError)
+	assert.True(t, ok)
+	assert.Equal(t, "gone", bulk.Errors[0].Name)
+	jobs, _ = folder.GetSubJobs()
+	assert.Equal(t, "b", jobs[1].GetName())
+}
+
+func TestTreeQuery(t *testing.T) {
+	tree := Tree("jobs", Fields("name", "color"), Tree("lastBuild", Fields("number", "result")))
+	assert.Equal(t, "jobs[name,color,lastBuild[number,result]]", tree.String())
+	assert.Equal(t, "allBuilds[number]{0,50}", Tree("allBuilds", Fields("number")).Range(0, 50).String())
+	assert.Equal(t, "builds{10,}", Tree("builds").Range(10, -1).String())
+	assert.Equal(t, "name,jobs[url]", TreeQuery(Fields("name"), Tree("jobs", Fields("url"))))
+}
+
+func TestBuildDiscarderRoundTrip(t *testing.T) {
+	config := getFileAsString("job.xml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/config.xml") {
+			if r.Method == "POST" {
+				body, _ := ioutil.ReadAll(r.Body)
+				config = string(body)
+			}
+			fmt.Fprint(w, config)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	job := &Job{Client: CreateJenkins(nil, server.URL), Raw: new(JobResponse), Base: "/job/test"}
+	discarder, err := job.GetBuildDiscarder()
+	assert.Nil(t, err)
+	assert.Nil(t, discarder)
+
+	err = job.SetBuildDiscarder(&BuildDiscarder{DaysToKeep: -1, NumToKeep: 10, ArtifactDaysToKeep: -1, ArtifactNumToKeep: 3})
+	assert.Nil(t, err)
+	err = job.SetBuildDiscarder(&BuildDiscarder{DaysToKeep: 7, NumToKeep: 20, ArtifactDaysToKeep: -1, ArtifactNumToKeep: -1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(config, "<jenkins.model.BuildDiscarderProperty>"))
+
+	discarder, err = job.GetBuildDiscarder()
+	assert.Nil(t, err)
+	assert.Equal(t, &BuildDiscarder{DaysToKeep: 7, NumToKeep: 20, ArtifactDaysToKeep: -1, ArtifactNumToKeep: -1}, discarder)
+	assert.Contains(t, config, "<name>params1</name>")
+	assert.True(t, strings.Index(config, "<description>") < strings.Index(config, "<properties>"))
+	assert.True(t, strings.Index(config, "</properties>") < strings.Index(config, "<scm"))
+
+	config = `<flow-definition plugin="workflow-job@2.40"><description>pipeline</description>` +
+		`<logRotator class="hudson.tasks.LogRotator"><daysToKeep>1</daysToKeep></logRotator></flow-definition>`
+	err = job.SetBuildDiscarder(&BuildDiscarder{DaysToKeep: 3, NumToKeep: -1, ArtifactDaysToKeep: -1, ArtifactNumToKeep: -1})
+	assert.Nil(t, err)
+	assert.NotContains(t, config, "<logRotator")
+	assert.Contains(t, config, `<flow-definition plugin="workflow-job@2.40">`)
+	discarder, err = job.GetBuildDiscarder()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, discarder.DaysToKeep)
+
+	err = job.SetBuildDiscarder(nil)
+	assert.Nil(t, err)
+	discarder, err = job.GetBuildDiscarder()
+	assert.Nil(t, err)
+	assert.Nil(t, discarder)
+}
+
+func TestBuildSelectors(t *testing.T) {
+	old := BuildSummary{Number: 3, Result: "FAILURE", Timestamp: 1000}
+	kept := BuildSummary{Number: 7, Result: "SUCCESS", Timestamp: time.Now().UnixNano() / int64(time.Millisecond), KeepLog: true}
+	assert.True(t, NumberBetween(3, 5)(old))
+	assert.False(t, NumberBetween(3, 5)(kept))
+	assert.True(t, OlderThan(time.Hour)(old))
+	assert.False(t, OlderThan(time.Hour)(kept))
+	assert.True(t, AllOf(ResultIn("FAILURE", "ABORTED"), NotKept())(old))
+	assert.False(t, AllOf(NumberBetween(1, 10), NotKept())(kept))
+}
+
+func TestNodeConfigRoundTrip(t *testing.T) {
+	config := new(NodeConfig)
+	err := unmarshalXML(getFileAsString("node_config.xml"), config)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"jdk8", "docker"}, config.GetLabels())
+	assert.Equal(t, SSH_LAUNCHER, config.Launcher.Class)
+	assert.Equal(t, 22, config.Launcher.Port)
+	assert.Equal(t, 0, *config.Launcher.MaxNumRetries)
+	assert.Equal(t, "/opt/jdk8", config.NodeProperties.Env["JAVA_HOME"])
+	assert.Equal(t, "jdk8", config.NodeProperties.ToolLocations[0].Name)
+
+	config.NumExecutors = 4
+	data, err := xml.Marshal(config)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "<setting>kept</setting>")
+	assert.Contains(t, string(data), "NonVerifyingKeyVerificationStrategy")
+	assert.Equal(t, 1, strings.Count(string(data), "<hudson.slaves.EnvironmentVariablesNodeProperty>"))
+
+	updated := new(NodeConfig)
+	err = unmarshalXML(string(data), updated)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, updated.NumExecutors)
+	assert.Equal(t, config.NodeProperties.Env, updated.NodeProperties.Env)
+	assert.Equal(t, config.NodeProperties.ToolLocations, updated.NodeProperties.ToolLocations)
+	assert.Equal(t, config.Launcher.Host, updated.Launcher.Host)
+}
+
+func TestNodeDrain(t *testing.T) {
+	var mu sync.Mutex
+	tempOffline := false
+	busyPolls := 2
+	stopped := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/toggleOffline"):
+			tempOffline = !tempOffline
+		case strings.HasSuffix(r.URL.Path, "/stop"):
+			stopped = append(stopped, r.URL.Path)
+		case strings.HasPrefix(r.URL.Path, "/computer/agent"):
+			executable := "null"
+			if busyPolls != 0 {
+				busyPolls--
+				executable = `{"number":7,"url":"` + "http://" + r.Host + `/job/deploy/7/"}`
+			}
+			fmt.Fprintf(w, `{"displayName":"agent","offline":%v,"temporarilyOffline":%v,"executors":[{"currentExecutable":%s}]}`, tempOffline, tempOffline, executable)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+	jenkins.PollInterval = time.Millisecond
+
+	node := &Node{Client: jenkins, Raw: new(NodeResponse), Base: "/computer/agent"}
+	err := node.Drain(context.Background(), "maintenance")
+	assert.Nil(t, err)
+	assert.True(t, tempOffline)
+	assert.Equal(t, 0, len(stopped))
+
+	busyPolls = -1
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = node.Drain(ctx, "maintenance")
+	assert.NotNil(t, err)
+	assert.True(t, tempOffline)
+	assert.Equal(t, []string{"/job/deploy/7/stop"}, stopped)
+
+	ok, err := node.SetOnline()
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.False(t, tempOffline)
+}
+
+func TestNodeHealth(t *testing.T) {
+	data := `{"displayName":"agent1","offline":false,"monitorData":{
+		"hudson.node_monitors.ArchitectureMonitor":"Linux (amd64)",
+		"hudson.node_monitors.ClockMonitor":{"diff":-7000},
+		"hudson.node_monitors.DiskSpaceMonitor":{"path":"/var/lib/jenkins","size":536870912},
+		"hudson.node_monitors.ResponseTimeMonitor":{"average":40},
+		"hudson.node_monitors.SwapSpaceMonitor":{"availableSwapSpace":-1},
+		"hudson.node_monitors.TemporarySpaceMonitor":{"path":"/tmp","size":4294967296}}}`
+	node := new(NodeResponse)
+	assert.Nil(t, json.Unmarshal([]byte(data), node))
+	assert.Equal(t, "Linux (amd64)", node.MonitorData.Hudson_NodeMonitors_ArchitectureMonitor)
+
+	health := checkNodeHealth(node, DefaultHealthThresholds)
+	assert.False(t, health.Healthy())
+	assert.Equal(t, []string{
+		"free disk space 512.0 MiB at /var/lib/jenkins is below 1.0 GiB",
+		"clock differs by -7s",
+	}, health.Problems)
+
+	offline := new(NodeResponse)
+	assert.Nil(t, json.Unmarshal([]byte(`{"displayName":"agent2","offline":true,"offlineCause":{"description":"disk replacement"},"monitorData":{}}`), offline))
+	health = checkNodeHealth(offline, DefaultHealthThresholds)
+	assert.Equal(t, []string{"offline: disk replacement"}, health.Problems)
+}
+
+func TestAgentJNLP(t *testing.T) {
+	agent, err := parseAgentJNLP(getFileAsString("agent.jnlp"))
+	assert.Nil(t, err)
+	assert.Equal(t, "4b1d2c8e9f0a", agent.Secret)
+	assert.Equal(t, "node1_test", agent.Name)
+	assert.Equal(t, "/var/lib/jenkins", agent.WorkDir)
+	assert.Equal(t, "remoting", agent.InternalDir)
+	assert.Equal(t, "http://localhost:8080/", agent.URL)
+	assert.Equal(t, "java -jar agent.jar -url http://localhost:8080/ -secret 4b1d2c8e9f0a -name node1_test -workDir /var/lib/jenkins", agent.CommandLine("agent.jar"))
+	assert.Equal(t, "4b1d2c8e9f0a", agent.DockerEnv()["JENKINS_SECRET"])
+	assert.Equal(t, "/var/lib/jenkins", agent.DockerEnv()["JENKINS_AGENT_WORKDIR"])
+}
+
+func TestRunningBuilds(t *testing.T) {
+	data := `{"displayName":"agent1","executors":[
+		{"number":0,"idle":false,"progress":80,"currentExecutable":{"number":12,"url":"http://ci/jenkins/job/team/job/deploy/12/","fullDisplayName":"team » deploy #12","timestamp":1000,"estimatedDuration":60000}},
+		{"number":1,"idle":true,"progress":-1,"currentExecutable":null},
+		{"number":2,"idle":false,"progress":10,"currentExecutable":{"number":5,"url":"http://ci/jenkins/job/matrix/jdk=8,os=linux/5/","timestamp":1000,"estimatedDuration":60000}}],
+		"oneOffExecutors":[{"number":-1,"progress":-1,"currentExecutable":{"number":3,"url":"http://ci/jenkins/job/pipeline/3/","timestamp":1000,"estimatedDuration":-1}}]}`
+	node := new(NodeResponse)
+	assert.Nil(t, json.Unmarshal([]byte(data), node))
+
+	c := CreateJenkins(nil, "http://ci/jenkins/")
+	builds := c.runningBuilds(node, time.Unix(121, 0))
+	assert.Equal(t, 3, len(builds))
+	assert.Equal(t, "team/deploy", builds[0].JobName)
+	assert.Equal(t, int64(12), builds[0].BuildNumber)
+	assert.Equal(t, 0, builds[0].Executor)
+	assert.Equal(t, 120*time.Second, builds[0].Elapsed)
+	assert.True(t, builds[0].OverEstimate())
+	assert.Equal(t, "matrix/jdk=8,os=linux", builds[1].JobName)
+	assert.Equal(t, int64(5), builds[1].BuildNumber)
+	assert.Equal(t, "pipeline", builds[2].JobName)
+	assert.Equal(t, -1, builds[2].Executor)
+	assert.Equal(t, time.Duration(-1), builds[2].EstimatedDuration)
+	assert.False(t, builds[2].OverEstimate())
+
+	name, number, ok := parseBuildEndpoint("/job/matrix/label=x/12/")
+	assert.True(t, ok)
+	assert.Equal(t, "matrix/label=x", name)
+	assert.Equal(t, int64(12), number)
+	assert.Equal(t, "/job/matrix/label=x", jobEndpoint(name))
+	assert.Equal(t, "/job/team/job/deploy", jobEndpoint("team/deploy"))
+}
+
 func getFileAsString(path string) string {
 	buf, err := ioutil.ReadFile("_tests/" + path)
 	if err != nil {
@@ -257,3 +577,947 @@ func getFileAsString(path string) string {
 
 	return string(buf)
 }
+
+func TestQueueWhyAndWatch(t *testing.T) {
+	assert.Equal(t, QueueWhy{Reason: WHY_WAITING_FOR_EXECUTOR, Label: "linux", Message: "Waiting for next available executor on ‘linux’"}, ClassifyWhy("Waiting for next available executor on ‘linux’"))
+	// Messages of Jenkins core, hudson/model/Messages.properties
+	assert.Equal(t, QueueWhy{Reason: WHY_BLOCKED_BY_UPSTREAM, Project: "ci", Message: "Upstream project ci is already in progress."}, ClassifyWhy("Upstream project ci is already in progress."))
+	assert.Equal(t, QueueWhy{Reason: WHY_BLOCKED_BY_DOWNSTREAM, Project: "folder/deploy", Message: "Downstream project folder/deploy is already in progress."}, ClassifyWhy("Downstream project folder/deploy is already in progress."))
+	assert.Equal(t, "ci", ClassifyWhy("Upstream project ‘ci’ is already in progress.").Project)
+	assert.Equal(t, QueueWhy{Reason: WHY_NO_NODE_FOR_LABEL, Label: "gpu", Message: "There are no nodes with the label ‘gpu’"}, ClassifyWhy("There are no nodes with the label ‘gpu’"))
+	assert.Equal(t, "linux", ClassifyWhy("All nodes of label ‘linux’ are offline").Label)
+	assert.Equal(t, WHY_ALREADY_RUNNING, ClassifyWhy("Build #12 is already in progress (ETA: 3 min 2 sec)").Reason)
+	assert.Equal(t, WHY_QUIET_PERIOD, ClassifyWhy("In the quiet period. Expires in 4.9 sec").Reason)
+	assert.Equal(t, WHY_THROTTLED, ClassifyWhy("Already running 2 builds across all nodes").Reason)
+	assert.Equal(t, WHY_UNKNOWN, ClassifyWhy("Warte auf den nächsten freien Build-Prozessor").Reason)
+
+	var mu sync.Mutex
+	items := `[{"id":1,"task":{"name":"a"},"inQueueSince":0},{"id":2,"task":{"name":"b"},"inQueueSince":%d}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/queue/item/1") {
+			fmt.Fprint(w, `{"_class":"hudson.model.Queue$LeftItem","id":1,"executable":{"number":3,"url":"http://`+r.Host+`/job/a/3/"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"items":`+items+`}`, time.Now().UnixNano()/int64(time.Millisecond))
+	}))
+	defer server.Close()
+
+	jenkins := CreateJenkins(nil, server.URL)
+	q, err := jenkins.GetQueue()
+	assert.Nil(t, err)
+	assert.Equal(t, "b", q.Tasks()[1].Raw.Task.Name)
+	build, err := q.Tasks()[1].GetBuild()
+	assert.Nil(t, build)
+	assert.Equal(t, ErrNoBuild, err)
+	_, err = q.CancelTask(42)
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := jenkins.WatchQueue(ctx, QueueWatchOptions{Interval: time.Millisecond, StuckAfter: time.Hour})
+	got := make([]string, 0)
+	for e := range events {
+		got = append(got, fmt.Sprintf("%s %d", e.Type, e.Task.Raw.ID))
+		if len(got) == 3 {
+			mu.Lock()
+			items = `[{"id":2,"task":{"name":"b"},"inQueueSince":%d}]`
+			mu.Unlock()
+		}
+		if e.Type == QUEUE_LEFT {
+			assert.True(t, e.Task.IsLeft())
+			assert.Equal(t, int64(3), e.Task.Raw.Executable.Number)
+			cancel()
+		}
+	}
+	assert.Equal(t, []string{"enqueued 1", "stuck 1", "enqueued 2", "left 1"}, got)
+}
+
+func TestLabelExpression(t *testing.T) {
+	e, err := ParseLabelExpression(`linux && (docker || podman) && !arm`)
+	assert.Nil(t, err)
+	assert.True(t, e.Matches([]string{"linux", "podman"}))
+	assert.False(t, e.Matches([]string{"linux", "docker", "arm"}))
+	assert.Equal(t, []string{"arm", "docker", "linux", "podman"}, e.Atoms())
+
+	e, err = ParseLabelExpression(`"jdk 11" -> ubuntu-22.04 <-> x86`)
+	assert.Nil(t, err)
+	assert.True(t, e.Matches([]string{"x86"}))
+	assert.True(t, e.Matches([]string{"jdk 11", "ubuntu-22.04", "x86"}))
+	assert.False(t, e.Matches([]string{"jdk 11", "x86"}))
+
+	e, _ = ParseLabelExpression(`linux && !(linux || mac)`)
+	assert.False(t, e.Satisfiable())
+	e, _ = ParseLabelExpression("")
+	assert.True(t, e.Matches(nil))
+
+	for _, invalid := range []string{"linux &&", "(linux", "linux & mac", `"linux`, "linux mac"} {
+		_, err = ParseLabelExpression(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"computer":[
+			{"displayName":"a","offline":false,"assignedLabels":[{"name":"a"},{"name":"linux"},{"name":"docker"}]},
+			{"displayName":"b","offline":true,"assignedLabels":[{"name":"b"},{"name":"linux"},{"name":"podman"}]},
+			{"displayName":"c","offline":false,"assignedLabels":[{"name":"c"},{"name":"linux"},{"name":"arm"},{"name":"docker"}]}]}`)
+	}))
+	defer server.Close()
+
+	match, err := CreateJenkins(nil, server.URL).GetNodesForLabelExpression(`linux && (docker || podman) && !arm`)
+	assert.Nil(t, err)
+	assert.True(t, match.Satisfied())
+	assert.Equal(t, 2, len(match.Nodes))
+	assert.Equal(t, 1, len(match.Online))
+	assert.Equal(t, "a", match.Online[0].GetName())
+}
+
+func TestLoadStatistics(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("tree")
+		fmt.Fprint(w, `{"_class":"hudson.model.Label$1",
+			"busyExecutors":{"sec10":{"latest":1.5},"min":{"history":[2,6,1],"latest":2.5},"hour":{"history":[3],"latest":3}},
+			"queueLength":{"min":{"history":[4],"latest":0.5}},
+			"totalExecutors":{"min":{"latest":8}}}`)
+	}))
+	defer server.Close()
+
+	label := &Label{Client: CreateJenkins(nil, server.URL), Raw: new(LabelResponse), Base: "/label/linux"}
+	stats, err := label.GetLoadStatistics()
+	assert.Nil(t, err)
+	assert.Contains(t, query, "busyExecutors[sec10[history,latest],min[history,latest],hour[history,latest]]")
+	assert.Equal(t, LoadSnapshot{Busy: 2.5, QueueLength: 0.5, Total: 8}, stats.Latest(TIMESCALE_MIN))
+	assert.Equal(t, 6.0, stats.Peak(TIMESCALE_MIN).Busy)
+	assert.Equal(t, 1.5, stats.Latest(TIMESCALE_SEC10).Busy)
+	assert.Equal(t, 3.0, stats.Peak(TIMESCALE_HOUR).Busy)
+}
+
+func TestCredentials(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			body, _ := ioutil.ReadAll(r.Body)
+			posted = append(posted, r.URL.Path+" "+string(body))
+		case strings.HasSuffix(r.URL.Path, "/credential/deploy/config.xml/"):
+			fmt.Fprint(w, `<com.cloudbees.plugins.credentials.impl.UsernamePasswordCredentialsImpl>
+  <scope>GLOBAL</scope><id>deploy</id><description>Deploy user</description>
+  <username>deployer</username><password><secret-redacted/></password>
+</com.cloudbees.plugins.credentials.impl.UsernamePasswordCredentialsImpl>`)
+		case strings.HasPrefix(r.URL.Path, "/credentials/store/system/api/json"):
+			fmt.Fprint(w, `{"domains":{"_":{"urlName":"_","global":true,"credentials":[{"id":"deploy","typeName":"Username with password"}]},
+				"github.com":{"urlName":"github.com","credentials":[{"id":"agent-ssh","typeName":"SSH Username with private key"}]}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+	store := jenkins.GetSystemCredentialStore()
+
+	secret := SecretTextCredentials{ID: "token", Secret: "s3cr3t"}
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v", secret, &secret, secret), "s3cr3t")
+	assert.Nil(t, store.Create("", secret))
+	assert.Nil(t, store.Create("github.com", FileCredentials{ID: "kubeconfig", FileName: "config", Content: []byte("apiVersion: v1")}))
+	assert.Nil(t, store.Delete("", "token"))
+	assert.Equal(t, []string{
+		"/credentials/store/system/domain/_/createCredentials <org.jenkinsci.plugins.plaincredentials.impl.StringCredentialsImpl><scope>GLOBAL</scope><id>token</id><description></description><secret>s3cr3t</secret></org.jenkinsci.plugins.plaincredentials.impl.StringCredentialsImpl>",
+		"/credentials/store/system/domain/github.com/createCredentials <org.jenkinsci.plugins.plaincredentials.impl.FileCredentialsImpl><scope>GLOBAL</scope><id>kubeconfig</id><description></description><fileName>config</fileName><secretBytes>YXBpVmVyc2lvbjogdjE=</secretBytes></org.jenkinsci.plugins.plaincredentials.impl.FileCredentialsImpl>",
+		"/credentials/store/system/domain/_/credential/token/doDelete ",
+	}, posted)
+
+	credential, err := store.Get("", "deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, UsernamePasswordCredentials{ID: "deploy", Description: "Deploy user", Scope: "GLOBAL", Username: "deployer"}, credential)
+
+	info, err := jenkins.FindCredential("agent-ssh")
+	assert.Nil(t, err)
+	assert.Equal(t, "github.com", info.Domain)
+	_, err = jenkins.FindCredential("missing")
+	assert.NotNil(t, err)
+}
+
+func TestRunScript(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scriptText" && r.URL.Path != "/computer/agent/scriptText" {
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		script := r.FormValue("script")
+		switch {
+		case strings.Contains(script, "throw"):
+			fmt.Fprint(w, "before\njava.lang.IllegalStateException: boom\n\tat Script1.run(Script1.groovy:2)\n\tat groovy.lang.GroovyShell.evaluate(GroovyShell.java:574)\n")
+		case strings.Contains(script, "JsonOutput"):
+			fmt.Fprint(w, "noise\n"+scriptResultMarker+`{"executors":2,"labels":["linux"]}`+"\n")
+		default:
+			fmt.Fprint(w, r.URL.Path+": "+script)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+	ctx := context.Background()
+
+	out, err := jenkins.RunScript(ctx, "println 1")
+	assert.Nil(t, err)
+	assert.Equal(t, "/scriptText: println 1", out)
+
+	node := &Node{Client: jenkins, Raw: new(NodeResponse), Base: "/computer/agent"}
+	out, err = node.RunScript(ctx, "println 2")
+	assert.Nil(t, err)
+	assert.Equal(t, "/computer/agent/scriptText: println 2", out)
+
+	out, err = jenkins.RunScript(ctx, "println 'before'; throw new IllegalStateException('boom')")
+	assert.Equal(t, "before", out)
+	scriptErr, ok := err.(*ScriptError)
+	assert.True(t, ok)
+	assert.Equal(t, "java.lang.IllegalStateException", scriptErr.Class)
+	assert.Equal(t, "boom", scriptErr.Message)
+	assert.Equal(t, 2, len(scriptErr.StackTrace))
+
+	var result struct {
+		Executors int
+		Labels    []string
+	}
+	assert.Nil(t, jenkins.RunScriptJSON(ctx, "import hudson.model.*\ndef executors() { 2 }\nreturn [executors: executors()]", &result))
+	assert.Equal(t, 2, result.Executors)
+	assert.Equal(t, []string{"linux"}, result.Labels)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = jenkins.RunScript(canceled, "println 1")
+	assert.NotNil(t, err)
+}
+
+// TestJSONScriptWrapper runs the wrapper of RunScriptJSON with a local Groovy, where it is installed.
+func TestJSONScriptWrapper(t *testing.T) {
+	groovy, err := exec.LookPath("groovy")
+	if err != nil {
+		t.Skip("groovy is not installed")
+	}
+	script := "import java.util.concurrent.TimeUnit\ndef twice(n) { 2 * n }\nprintln 'it\\'s printed'\nreturn [executors: twice(1), unit: TimeUnit.SECONDS.name()]"
+	out, err := exec.Command(groovy, "-e", jsonScript(script)).CombinedOutput()
+	assert.Nil(t, err, string(out))
+	assert.Contains(t, string(out), "it's printed")
+	var result struct {
+		Executors int
+		Unit      string
+	}
+	assert.Nil(t, decodeScriptResult(string(out), &result))
+	assert.Equal(t, 2, result.Executors)
+	assert.Equal(t, "SECONDS", result.Unit)
+}
+
+func TestLifecycle(t *testing.T) {
+	var mu sync.Mutex
+	actions := make([]string, 0)
+	version, session, stillUp, down := "2.400", "a", 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == "POST" && r.URL.Path != "/" {
+			actions = append(actions, r.URL.Path+" "+r.URL.Query().Get("reason"))
+			if r.URL.Path == "/safeRestart" {
+				// Jenkins keeps answering while it waits for the running builds.
+				stillUp, down = 3, 2
+			}
+			if r.URL.Path == "/restart" {
+				// The restart is over before the client polls.
+				session = "d"
+			}
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		switch {
+		case stillUp > 0:
+			stillUp--
+		case down > 0:
+			down--
+			if down == 0 {
+				version, session = "2.440", "b"
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Jenkins", version)
+		w.Header().Set("X-Jenkins-Session", session)
+		fmt.Fprintf(w, `{"quietingDown":%v}`, len(actions) == 1)
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+	jenkins.PollInterval = time.Millisecond
+
+	assert.Nil(t, jenkins.QuietDown("upgrade"))
+	quieting, err := jenkins.IsQuietingDown()
+	assert.Nil(t, err)
+	assert.True(t, quieting)
+	assert.Nil(t, jenkins.SafeRestart())
+	assert.Equal(t, []string{"/quietDown upgrade", "/safeRestart "}, actions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, jenkins.WaitUntilReady(ctx))
+	assert.Equal(t, "2.440", jenkins.Version)
+	mu.Lock()
+	assert.Equal(t, 0, stillUp+down)
+	mu.Unlock()
+
+	// Without SafeRestart or Restart, the version alone tells that the upgrade is done.
+	mu.Lock()
+	stillUp = 2
+	mu.Unlock()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		version, session = "2.450", "c"
+		mu.Unlock()
+	}()
+	assert.Nil(t, jenkins.WaitUntilReady(ctx, "2.450"))
+	assert.Equal(t, "2.450", jenkins.Version)
+
+	assert.Nil(t, jenkins.Restart())
+	assert.Nil(t, jenkins.WaitUntilReady(ctx))
+
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+	err = jenkins.WaitUntilReady(short, "2.500")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "runs version 2.450 instead of 2.500")
+
+	fresh := CreateJenkins(nil, server.URL)
+	fresh.PollInterval = time.Millisecond
+	assert.Nil(t, fresh.WaitUntilReady(ctx, "2.450"))
+	err = fresh.WaitUntilReady(short)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not gone down")
+}
+
+func TestInstallPlugins(t *testing.T) {
+	var mu sync.Mutex
+	var installed string
+	actions := make([]string, 0)
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/pluginManager/installNecessaryPlugins":
+			body, _ := ioutil.ReadAll(r.Body)
+			installed = string(body)
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/pluginManager/plugin/"):
+			actions = append(actions, r.URL.Path)
+		case strings.HasPrefix(r.URL.Path, "/updateCenter"):
+			status := "Installing"
+			if installed != "" {
+				polls++
+				if polls > 2 {
+					status = "SuccessButRequiresRestart"
+				}
+			}
+			fmt.Fprint(w, `{"jobs":[{"id":1,"type":"ConnectionCheckJob"}`)
+			if installed != "" {
+				fmt.Fprintf(w, `,{"id":2,"type":"InstallationJob","name":"git","status":{"type":%q},"plugin":{"name":"git","version":"5.2.0"}}`, status)
+				fmt.Fprint(w, `,{"id":3,"type":"InstallationJob","name":"broken","status":{"type":"Failure"},"errorMessage":"checksum mismatch"}`)
+			}
+			fmt.Fprint(w, `]}`)
+		case strings.HasPrefix(r.URL.Path, "/pluginManager/api/json"):
+			fmt.Fprint(w, `{"plugins":[{"shortName":"git","active":true,"enabled":true},{"shortName":"ldap","active":true,"enabled":false},{"shortName":"old","active":true,"enabled":true,"deleted":true}]}`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+	jenkins.PollInterval = time.Millisecond
+
+	spec, err := ParsePluginSpec("git@5.2.0")
+	assert.Nil(t, err)
+	report, err := jenkins.InstallPlugins(context.Background(), []PluginSpec{spec, {Name: "broken"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, `<jenkins><install plugin="git@5.2.0"/><install plugin="broken@0"/></jenkins>`, installed)
+	assert.True(t, report.RestartRequired)
+	assert.Equal(t, []PluginInstallResult{
+		{Name: "git", Version: "5.2.0", Status: PLUGIN_INSTALL_RESTART_NEEDED},
+		{Name: "broken", Status: PLUGIN_INSTALL_FAILURE, Error: "checksum mismatch"},
+	}, report.Results)
+
+	assert.Nil(t, jenkins.DisablePlugin("ldap"))
+	assert.Nil(t, jenkins.UninstallPlugin("old"))
+	assert.Equal(t, []string{"/pluginManager/plugin/ldap/makeDisabled", "/pluginManager/plugin/old/doUninstall"}, actions)
+
+	restart, err := jenkins.GetPluginRestartReport()
+	assert.Nil(t, err)
+	assert.True(t, restart.Required)
+	assert.Equal(t, []PendingPluginChange{{"ldap", PLUGIN_CHANGE_DISABLE}, {"old", PLUGIN_CHANGE_UNINSTALL}}, restart.Changes)
+}
+
+func TestPluginGraph(t *testing.T) {
+	assert.Equal(t, -1, CompareVersions("1.0-beta-2", "1.0"))
+	assert.Equal(t, -1, CompareVersions("1.0", "1.0.1"))
+	assert.Equal(t, -1, CompareVersions("1.9", "1.10"))
+	assert.Equal(t, 0, CompareVersions("2.3", "2.3"))
+	assert.Equal(t, 1, CompareVersions("4.11.3", "4.11-rc1"))
+	assert.Equal(t, 0, CompareVersions("1.0.0", "1.0"))
+	assert.Equal(t, 0, CompareVersions("2", "2.0.0"))
+	assert.Equal(t, 1, CompareVersions("1.0.0.1", "1.0"))
+	assert.Equal(t, -1, CompareVersions("1.0.0-beta", "1.0"))
+
+	var resp PluginResponse
+	err := json.Unmarshal([]byte(`{"plugins":[
+		{"shortName":"git","version":"5.2.0","enabled":true,"dependencies":[
+			{"shortName":"scm-api","version":"680.v3a_ca_","optional":false},
+			{"shortName":"credentials","version":"1300.v1","optional":false},
+			{"shortName":"workflow-step-api","version":"640","optional":true}]},
+		{"shortName":"scm-api","version":"600.v1","enabled":true,"dependencies":[{"shortName":"structs","version":"1.20","optional":false}]},
+		{"shortName":"credentials","version":"1300.v1","enabled":false,"dependencies":[{"shortName":"structs","version":"1.22","optional":false}]},
+		{"shortName":"structs","version":"1.23","enabled":true}]}`), &resp)
+	assert.Nil(t, err)
+	graph := NewPluginGraph(resp.Plugins)
+
+	assert.Equal(t, []string{"credentials", "scm-api"}, graph.Dependents("structs"))
+	problems := graph.Problems()
+	assert.Equal(t, 2, len(problems))
+	assert.Equal(t, "git requires scm-api 680.v3a_ca_, but 600.v1 is installed", problems[0].String())
+	assert.Equal(t, PLUGIN_DISABLED_DEPENDENCY, problems[1].Kind)
+
+	required, err := graph.RequiredFor("git", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []PluginSpec{{"structs", "1.22"}, {"scm-api", "680.v3a_ca_"}, {"credentials", "1300.v1"}, {"git", "5.2.0"}}, required)
+	_, err = graph.RequiredFor("git", true)
+	assert.Contains(t, err.Error(), "workflow-step-api is neither installed nor available")
+	_, err = graph.RequiredFor("pipeline", false)
+	assert.NotNil(t, err)
+	graph.AddAvailable([]UpdateSitePlugin{
+		{Name: "workflow-step-api", Version: "650", Dependencies: map[string]string{"structs": "1.24"}},
+		{Name: "pipeline", Version: "600", Dependencies: map[string]string{"git": "5.0", "workflow-step-api": "645"},
+			OptionalDependencies: map[string]string{"blueocean": "1.0"}},
+		{Name: "git", Version: "5.3.0"},
+	})
+	required, err = graph.RequiredFor("git", true)
+	assert.Nil(t, err)
+	assert.Equal(t, PluginSpec{"workflow-step-api", "640"}, required[3])
+	required, err = graph.RequiredFor("pipeline", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []PluginSpec{{"structs", "1.24"}, {"scm-api", "680.v3a_ca_"}, {"credentials", "1300.v1"},
+		{"git", "5.0"}, {"workflow-step-api", "645"}, {"pipeline", "600"}}, required)
+
+	assert.Contains(t, graph.DOT(), `"git" -> "workflow-step-api" [label="640", style=dashed];`)
+	assert.Contains(t, graph.DOT(), `"workflow-step-api" [color=red, fontcolor=red];`)
+	data, err := json.Marshal(graph)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `{"name":"workflow-step-api","enabled":false,"missing":true}`)
+}
+
+func TestPluginsRequiredFor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pluginManager/api/json":
+			fmt.Fprint(w, `{"plugins":[{"shortName":"structs","version":"1.23","enabled":true}]}`)
+		case "/updateCenter/api/json":
+			fmt.Fprint(w, `{"sites":[{"availables":[{"name":"git","version":"5.2.0","dependencies":{"scm-api":"680"},"optionalDependencies":{"pipeline":"1"}},
+				{"name":"scm-api","version":"690","dependencies":{"structs":"1.22"}}],"updates":[{"name":"structs","version":"1.24"}]}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	required, err := CreateJenkins(nil, server.URL).PluginsRequiredFor("git", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []PluginSpec{{"structs", "1.22"}, {"scm-api", "680"}, {"git", "5.2.0"}}, required)
+}
+
+func TestPluginSet(t *testing.T) {
+	plugins := &Plugins{Raw: &PluginResponse{Plugins: []Plugin{
+		{ShortName: "git", Version: "5.2.0"},
+		{ShortName: "credentials", Version: "1300.v1"},
+		{ShortName: "old", Version: "1.0", Deleted: true},
+	}}}
+	assert.Equal(t, "credentials:1300.v1\ngit:5.2.0\n", plugins.PluginsTxt())
+
+	set, err := ParsePluginsTxt(strings.NewReader("# production\ngit:5.1.0\n\ncredentials:1300.v1 # pinned\nmatrix-auth\nldap:2.0\nblueocean:latest:https://example.com/blueocean.hpi\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, PluginSet{"git": "5.1.0", "credentials": "1300.v1", "matrix-auth": "latest", "ldap": "2.0", "blueocean": "latest"}, set)
+	_, err = ParsePluginsTxt(strings.NewReader("git:1\ngit:2\n"))
+	assert.NotNil(t, err)
+
+	diff := DiffPluginSets(plugins.Set(), set)
+	assert.Equal(t, []PluginSpec{{"blueocean", "latest"}, {"ldap", "2.0"}, {"matrix-auth", "latest"}}, diff.Added)
+	assert.Equal(t, 0, len(diff.Removed))
+	assert.Equal(t, []PluginVersionChange{{"git", "5.2.0", "5.1.0"}}, diff.Downgraded)
+	assert.False(t, diff.Empty())
+	assert.True(t, DiffPluginSets(set, set).Empty())
+	assert.True(t, DiffPluginSets(PluginSet{"ldap": "1.0"}, PluginSet{"ldap": "1.0.0"}).Empty())
+}
+
+func TestPluginUpdateReport(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/update-center.json":
+			fmt.Fprint(w, `updateCenter.post(
+{"core":{"version":"2.450"},
+ "plugins":{"git":{"name":"git","version":"5.2.1","requiredCore":"2.440"},"ldap":{"name":"ldap","version":"2.0","requiredCore":"2.500"}},
+ "warnings":[
+  {"id":"SECURITY-1","type":"plugin","name":"git","message":"XSS","url":"https://www.jenkins.io/security/advisory/","versions":[{"lastVersion":"5.2.0","pattern":"([1-4]|5[.][01]|5[.]2[.]0)([.-].*)?"}]},
+  {"id":"SECURITY-2","type":"core","name":"core","versions":[{"lastVersion":"2.439","pattern":"2[.]4[0-3][0-9]"}]},
+  {"id":"SECURITY-3","type":"plugin","name":"internal","versions":[{"lastVersion":"1.0","pattern":"(?<!2)1[.]0"}]}]}
+);`)
+		case strings.HasPrefix(r.URL.Path, "/updateCenter"):
+			fmt.Fprintf(w, `{"sites":[{"id":"experimental","url":"%s/experimental/update-center.json"},
+				{"id":"default","url":"%s/update-center.json","hasUpdates":true,"updates":[{"name":"git","version":"5.2.1"}]}]}`, server.URL, server.URL)
+		case strings.HasPrefix(r.URL.Path, "/pluginManager"):
+			fmt.Fprint(w, `{"plugins":[{"shortName":"git","version":"5.2.0"},{"shortName":"ldap","version":"2.0"},{"shortName":"internal","version":"1.0"}]}`)
+		default:
+			w.Header().Set("X-Jenkins", "2.430")
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+
+	sites, err := jenkins.GetUpdateSites()
+	assert.Nil(t, err)
+	assert.Equal(t, "5.2.1", sites[1].Updates[0].Version)
+
+	report, err := jenkins.GetPluginUpdateReport()
+	assert.Nil(t, err)
+	assert.Equal(t, "2.430", report.Core)
+	assert.Equal(t, "SECURITY-2", report.CoreWarnings[0].ID)
+	assert.Equal(t, 3, len(report.Plugins))
+
+	vulnerable := report.Vulnerable()
+	assert.Equal(t, 1, len(vulnerable))
+	assert.Equal(t, "git", vulnerable[0].Name)
+	assert.True(t, vulnerable[0].FixedByUpdate())
+	assert.False(t, vulnerable[0].CoreCompatible)
+	assert.Equal(t, 1, len(report.Updates()))
+	assert.Equal(t, "", report.Plugins[1].Available)
+	// a Java pattern that Go cannot compile is reported, not taken as unaffected
+	assert.Equal(t, 1, len(report.Errors))
+	assert.Contains(t, report.Errors[0].Error(), "internal 1.0: warning SECURITY-3")
+}
+
+func TestUsersAndAPITokens(t *testing.T) {
+	var mu sync.Mutex
+	revoked := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/whoAmI"):
+			fmt.Fprint(w, `{"name":"deploy-bot","anonymous":false,"authenticated":true,"authorities":["authenticated","deployers"]}`)
+		case strings.HasPrefix(r.URL.Path, "/me/"):
+			fmt.Fprint(w, `{"id":"deploy-bot","fullName":"Deploy Bot","property":[{"_class":"hudson.tasks.Mailer$UserProperty","address":"bot@example.com"}]}`)
+		case strings.HasPrefix(r.URL.Path, "/asynchPeople"):
+			fmt.Fprint(w, `{"users":[{"lastChange":1700000000000,"user":{"id":"alice","fullName":"Alice"}},{"lastChange":null,"user":{"id":"deploy-bot"}}]}`)
+		case strings.HasSuffix(r.URL.Path, "/generateNewToken"):
+			assert.Equal(t, "/user/deploy-bot/descriptorByName/jenkins.security.ApiTokenProperty/generateNewToken", r.URL.Path)
+			fmt.Fprintf(w, `{"status":"ok","data":{"tokenName":%q,"tokenUuid":"new","tokenValue":"11abcdef"}}`, r.URL.Query().Get("newTokenName"))
+		case strings.HasSuffix(r.URL.Path, "/revoke"):
+			revoked = append(revoked, r.URL.Query().Get("tokenUuid"))
+		case r.URL.Path == "/scriptText":
+			assert.Contains(t, r.FormValue("script"), `getById(\'deploy-bot\', false)`)
+			fmt.Fprint(w, scriptResultMarker+`[{"uuid":"old","name":"ci","creationDate":1700000000000,"useCounter":3},{"uuid":"other","name":"laptop"}]`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+
+	who, err := jenkins.WhoAmI()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"authenticated", "deployers"}, who.Authorities)
+	assert.Equal(t, "Deploy Bot", who.User.GetFullName())
+	assert.Equal(t, "bot@example.com", who.User.GetEmail())
+
+	users, err := jenkins.GetUsers()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, int64(1700000000), users[0].LastChange.Unix())
+	assert.True(t, users[1].LastChange.IsZero())
+
+	token, err := who.User.RotateAPIToken(context.Background(), "ci")
+	assert.Nil(t, err)
+	assert.Equal(t, "11abcdef", token.Value)
+	assert.NotContains(t, fmt.Sprintf("%v %+v", token, *token), "11abcdef")
+	assert.Equal(t, []string{"old"}, revoked)
+
+	token, err = who.User.ReplaceAPIToken("new", "ci")
+	assert.Nil(t, err)
+	assert.Equal(t, "11abcdef", token.Value)
+	assert.Equal(t, []string{"old", "new"}, revoked)
+	assert.Equal(t, `'it\'s'`, groovyString("it's"))
+}
+
+func TestViewConfig(t *testing.T) {
+	var mu sync.Mutex
+	config := `<?xml version="1.1" encoding="UTF-8"?>
+<hudson.model.ListView>
+  <name>team</name>
+  <columns>
+    <hudson.views.StatusColumn/>
+    <hudson.views.JobColumn/>
+    <hudson.views.BuildButtonColumn/>
+  </columns>
+  <includeRegex>old.*</includeRegex>
+  <recurse>false</recurse>
+</hudson.model.ListView>`
+	requests := make([]string, 0)
+	primary := "/job/ops/view/all/view/squad/doDelete"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == primary:
+			requests = append(requests, r.URL.Path+" "+r.URL.Query().Get("name"))
+			w.WriteHeader(http.StatusBadRequest)
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/config.xml"):
+			body, _ := ioutil.ReadAll(r.Body)
+			config = string(body)
+		case r.Method == "POST":
+			requests = append(requests, r.URL.Path+" "+r.URL.Query().Get("name"))
+		case strings.HasSuffix(r.URL.Path, "/config.xml/"):
+			fmt.Fprint(w, config)
+		case strings.HasPrefix(r.URL.Path, "/job/ops/view/all/"):
+			fmt.Fprint(w, `{"_class":"hudson.plugins.nested_view.NestedView","name":"all","views":[{"name":"team","url":"x"}]}`)
+		default:
+			fmt.Fprint(w, `{"name":"team"}`)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+
+	folder := &Folder{Client: jenkins, Raw: new(FolderResponse), Base: "/job/ops"}
+	nested, err := folder.GetView("all")
+	assert.Nil(t, err)
+	assert.Equal(t, NESTED_VIEW, nested.Raw.Class)
+	assert.Equal(t, "team", nested.GetViews()[0].Name)
+	view, err := nested.GetView("team")
+	assert.Nil(t, err)
+	assert.Equal(t, "/job/ops/view/all/view/team", view.Base)
+
+	assert.Nil(t, view.SetIncludeRegex("release-.*"))
+	assert.Contains(t, config, "<includeRegex>release-.*</includeRegex>")
+	assert.NotContains(t, config, "old.*")
+
+	assert.Nil(t, view.SetColumns(STATUS_COLUMN, WEATHER_COLUMN, JOB_COLUMN))
+	columns, err := view.GetColumns()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{STATUS_COLUMN, WEATHER_COLUMN, JOB_COLUMN}, columns)
+	assert.Contains(t, config, "<recurse>false</recurse>")
+	assert.True(t, strings.Index(config, "<name>team</name>") < strings.Index(config, "<columns>"))
+
+	spaced, err := nested.GetView("qa team")
+	assert.Nil(t, err)
+	assert.Equal(t, "/job/ops/view/all/view/qa%20team", spaced.Base)
+
+	assert.Nil(t, view.Rename("squad"))
+	assert.Equal(t, []string{"/job/ops/view/all/createView squad", "/job/ops/view/all/view/team/doDelete "}, requests)
+	assert.Equal(t, "/job/ops/view/all/view/squad", view.Base)
+
+	// The view cannot be deleted, the copy is removed again.
+	requests = requests[:0]
+	err = view.Rename("crew")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"/job/ops/view/all/createView crew", "/job/ops/view/all/view/squad/doDelete ", "/job/ops/view/all/view/crew/doDelete "}, requests)
+	assert.Equal(t, "/job/ops/view/all/view/squad", view.Base)
+}
+
+func TestViewTree(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/json":
+			fmt.Fprint(w, `{"jobs":[{"_class":"hudson.model.FreeStyleProject","name":"build"},{"_class":"com.cloudbees.hudson.plugins.folder.Folder","name":"ops","views":[]},
+				{"_class":"org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject","name":"repo","views":[{"name":"default"}]}],
+				"views":[{"_class":"hudson.model.AllView","name":"all"},{"_class":"hudson.plugins.nested_view.NestedView","name":"teams"}]}`)
+		case "/view/all/api/json":
+			fmt.Fprint(w, `{"_class":"hudson.model.AllView","name":"all","jobs":[{"name":"build"},{"name":"ops"}],"views":[{"name":"all"},{"name":"teams"}]}`)
+		case "/view/teams/api/json":
+			fmt.Fprint(w, `{"_class":"hudson.plugins.nested_view.NestedView","name":"teams","views":[{"_class":"hudson.model.ListView","name":"web"},{"_class":"hudson.model.ListView","name":"gone"}]}`)
+		case "/view/teams/view/web/api/json":
+			fmt.Fprint(w, `{"_class":"hudson.model.ListView","name":"web","jobs":[{"name":"build"}]}`)
+		case "/job/ops/api/json":
+			fmt.Fprint(w, `{"_class":"com.cloudbees.hudson.plugins.folder.Folder","name":"ops","jobs":[{"name":"deploy"}],"views":[{"_class":"hudson.model.ListView","name":"prod"},{"_class":"hudson.model.ListView","name":"qa #1?"}]}`)
+		case "/job/ops/view/prod/api/json":
+			fmt.Fprint(w, `{"_class":"hudson.model.ListView","name":"prod","jobs":[{"name":"deploy"}]}`)
+		case "/job/ops/view/qa #1?/api/json":
+			fmt.Fprint(w, `{"_class":"hudson.model.ListView","name":"qa #1?","jobs":[]}`)
+		case "/job/repo/api/json":
+			fmt.Fprint(w, `{"_class":"org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject","name":"repo","jobs":[{"name":"main"}],"views":[{"name":"default"}]}`)
+		case "/job/repo/view/default/api/json":
+			fmt.Fprint(w, `{"name":"default","jobs":[{"name":"main"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tree, err := CreateJenkins(nil, server.URL).GetViewTree()
+	assert.Nil(t, err)
+	paths := make([]string, 0)
+	tree.Walk(func(n *ViewTreeNode, depth int) {
+		paths = append(paths, fmt.Sprintf("%d %s %s %d", depth, n.Kind, n.Base, len(n.Jobs)))
+	})
+	assert.Equal(t, []string{
+		"0 root  3",
+		"1 view /view/all 2",
+		"1 view /view/teams 0",
+		"2 view /view/teams/view/web 1",
+		"2 view /view/teams/view/gone 0",
+		"1 folder /job/ops 1",
+		"2 view /job/ops/view/prod 1",
+		"2 view /job/ops/view/qa%20%231%3F 0",
+		"1 folder /job/repo 1",
+		"2 view /job/repo/view/default 1",
+	}, paths)
+	failed := tree.Failed()
+	assert.Equal(t, 1, len(failed))
+	assert.Equal(t, "gone", failed[0].Name)
+}
+
+func TestBuildDependencyGraph(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job := func(name string) string {
+			return fmt.Sprintf(`{"name":%q,"url":"%s/job/%s/"}`, name, server.URL, strings.Replace(name, "/", "/job/", -1))
+		}
+		switch r.URL.EscapedPath() {
+		case "/job/build/api/json":
+			fmt.Fprintf(w, `{"_class":"hudson.model.FreeStyleProject","url":"%s/job/build/","downstreamProjects":[%s]}`, server.URL, job("test"))
+		case "/job/release/job/promote/api/json":
+			// a Pipeline starting a job with a build step and a parameterized trigger
+			fmt.Fprintf(w, `{"builds":[{"actions":[{"causes":[{"upstreamProject":"release/deploy","upstreamBuild":1}]},
+				{"downstreamBuilds":[{"jobFullName":"notify team","buildNumber":null}]},{"triggeredBuilds":[{"url":"%s/job/audit/4/"}]}]}]}`, server.URL)
+		case "/job/notify%20team/api/json", "/job/audit/api/json":
+			fmt.Fprint(w, `{}`)
+		case "/job/test/api/json":
+			fmt.Fprintf(w, `{"upstreamProjects":[%s],"downstreamProjects":[]}`, job("build"))
+		case "/job/release/job/deploy/api/json":
+			fmt.Fprint(w, `{"builds":[{"actions":[{"causes":[{"upstreamProject":"test","upstreamBuild":3}]}]},{"actions":[{}]}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	graph, err := CreateJenkins(nil, server.URL).BuildDependencyGraph([]string{"build", "release/promote"}, JobGraphOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"audit", "build", "notify team", "release/deploy", "release/promote", "test"}, graph.Names())
+	// release/deploy is only found through the cause of release/promote, test through the graph.
+	assert.Equal(t, []string{"test"}, graph.Upstream("release/deploy"))
+	assert.Equal(t, 0, len(graph.Failed()))
+	order, err := graph.TopologicalOrder()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "test", "release/deploy", "release/promote", "audit", "notify team"}, order)
+	assert.Equal(t, []JobGraphEdge{
+		{From: "build", To: "test", Declared: true},
+		{From: "release/deploy", To: "release/promote", Cause: true},
+		{From: "release/promote", To: "audit", Cause: true},
+		{From: "release/promote", To: "notify team", Cause: true},
+		{From: "test", To: "release/deploy", Cause: true},
+	}, graph.Edges())
+	assert.Contains(t, graph.DOT(), `"test" -> "release/deploy" [style=dashed];`)
+	assert.Contains(t, graph.Mermaid(), "j1 --> j5")
+	data, err := json.Marshal(graph)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `{"from":"build","to":"test","declared":true,"cause":false}`)
+
+	g := newJobGraph()
+	for _, n := range []string{"a", "b", "c", "d"} {
+		g.nodes[n] = &JobGraphNode{Name: n}
+	}
+	g.addEdge("a", "b", JOB_EDGE_DECLARED)
+	g.addEdge("b", "a", JOB_EDGE_CAUSE)
+	g.addEdge("c", "c", JOB_EDGE_DECLARED)
+	g.addEdge("c", "d", JOB_EDGE_DECLARED)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, g.Cycles())
+	_, err = g.TopologicalOrder()
+	assert.NotNil(t, err)
+}
+
+func TestTraceBuild(t *testing.T) {
+	var server *httptest.Server
+	var mu sync.Mutex
+	broken := false
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		upstream := func(class, job string, number int) string {
+			return fmt.Sprintf(`{"causes":[{"_class":%q,"upstreamProject":%q,"upstreamBuild":%d}]}`, class, job, number)
+		}
+		switch r.URL.Path {
+		case "/job/build/api/json":
+			fmt.Fprintf(w, `{"name":"build","downstreamProjects":[{"name":"test","url":"%s/job/test/"}]}`, server.URL)
+		case "/job/build/1/api/json":
+			fmt.Fprintf(w, `{"number":1,"url":"%s/job/build/1/","result":"SUCCESS","duration":60000,"timestamp":1500000000000,
+				"actions":[{"causes":[{"_class":"hudson.model.Cause$UserIdCause"}]},{"triggeredBuilds":[{"url":"%s/job/deploy/3/"}]},
+				{"downstreamBuilds":[{"jobFullName":"release/promote","buildNumber":2},{"jobFullName":"release/promote","buildNumber":null}]}]}`, server.URL, server.URL)
+		case "/job/test/api/json":
+			fmt.Fprintf(w, `{"name":"test","allBuilds":[{"number":3},{"number":4},{"number":6},{"number":5}],
+				"builds":[{"number":5,"actions":[%s]},{"number":4,"actions":[{"causes":[{"_class":"hudson.model.Cause$UserIdCause"}]}]}]}`,
+				upstream(UPSTREAM_CAUSE, "build", 1))
+		case "/job/test/3/api/json":
+			// started by a job in a folder which was deleted since
+			fmt.Fprintf(w, `{"number":3,"actions":[{"causes":[{"_class":"hudson.model.Cause$UserIdCause"},%s]}]}`,
+				`{"upstreamProject":"gone/build","upstreamBuild":1}`)
+		case "/job/test/6/api/json":
+			if broken {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			fmt.Fprintf(w, `{"number":6,"url":"%s/job/test/6/","actions":[{"causes":[{"_class":"hudson.model.Cause$UserIdCause"},
+				{"upstreamProject":"build","upstreamBuild":1}]}]}`, server.URL)
+		case "/job/test/4/api/json":
+			fmt.Fprint(w, `{"number":4,"actions":[{"causes":[{"_class":"hudson.model.Cause$UserIdCause"}]}]}`)
+		case "/job/test/5/api/json":
+			fmt.Fprintf(w, `{"number":5,"url":"%s/job/test/5/","result":"FAILURE","actions":[%s]}`, server.URL, upstream(UPSTREAM_CAUSE, "build", 1))
+		case "/job/deploy/api/json", "/job/release/job/promote/api/json":
+			fmt.Fprint(w, `{}`)
+		case "/job/deploy/3/api/json":
+			fmt.Fprintf(w, `{"number":3,"building":true,"actions":[%s]}`, upstream(UPSTREAM_CAUSE, "build", 1))
+		case "/job/release/job/promote/2/api/json":
+			fmt.Fprintf(w, `{"number":2,"result":"SUCCESS","actions":[%s]}`, upstream(BUILD_STEP_CAUSE, "build", 1))
+		case "/job/notify/api/json":
+			fmt.Fprintf(w, `{"builds":[{"number":7,"actions":[%s]}]}`, upstream(BUILD_STEP_CAUSE, "test", 5))
+		case "/job/notify/7/api/json":
+			fmt.Fprintf(w, `{"number":7,"result":"SUCCESS","actions":[%s]}`, upstream(BUILD_STEP_CAUSE, "test", 5))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+
+	trace, err := jenkins.TraceBuild("build", 1, BuildTraceOptions{Jobs: []string{"notify"}})
+	assert.Nil(t, err)
+	nodes := make([]string, 0)
+	trace.Walk(func(n *BuildTraceNode, depth int) {
+		nodes = append(nodes, fmt.Sprintf("%d %s#%d %s %s", depth, n.JobName, n.Number, n.Result, n.CauseClass))
+	})
+	assert.Equal(t, []string{
+		"0 build#1 SUCCESS ",
+		"1 deploy#3  " + UPSTREAM_CAUSE,
+		"1 release/promote#2 SUCCESS " + BUILD_STEP_CAUSE,
+		"1 test#5 FAILURE " + UPSTREAM_CAUSE,
+		"2 notify#7 SUCCESS " + BUILD_STEP_CAUSE,
+	}, nodes)
+	assert.Equal(t, time.Minute, trace.Duration)
+	assert.False(t, trace.Done())
+	assert.Equal(t, 0, len(trace.Failed()))
+
+	_, err = jenkins.TraceBuild("missing", 1, BuildTraceOptions{})
+	assert.NotNil(t, err)
+
+	// Builds without upstream cause used to panic, builds of deleted upstream jobs are skipped
+	// and every cause is checked.
+	job, err := jenkins.GetJob("build")
+	assert.Nil(t, err)
+	build, err := job.GetBuild(1)
+	assert.Nil(t, err)
+	downstream, err := build.GetDownstreamBuilds()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(downstream))
+	assert.Equal(t, int64(6), downstream[0].GetBuildNumber())
+
+	// Transport errors are returned.
+	mu.Lock()
+	broken = true
+	mu.Unlock()
+	_, err = build.GetDownstreamBuilds()
+	assert.NotNil(t, err)
+}
+
+func TestMultibranchProject(t *testing.T) {
+	var mu sync.Mutex
+	requests := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath()+" "+r.URL.RawQuery)
+		switch strings.TrimSuffix(r.URL.EscapedPath(), "/") {
+		case "/job/org/job/repo/api/json":
+			fmt.Fprint(w, `{"_class":"org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject","name":"repo","jobs":[
+				{"name":"main","actions":[{"_class":"jenkins.scm.api.metadata.ObjectMetadataAction","objectDisplayName":"main","objectUrl":"https://git/repo/tree/main"},
+					{"_class":"jenkins.scm.api.metadata.PrimaryInstanceMetadataAction"},{}],"lastBuild":{"number":7}},
+				{"name":"PR-release","actions":[{"_class":"jenkins.scm.api.metadata.ObjectMetadataAction","objectDisplayName":"PR-release"}]},
+				{"name":"PR-12","actions":[{"_class":"jenkins.scm.api.metadata.ObjectMetadataAction","objectDisplayName":"Fix login","objectUrl":"https://git/repo/pull/12"},
+					{"_class":"jenkins.scm.api.metadata.ContributorMetadataAction","contributor":"jdoe","contributorEmail":"jdoe@example.com"}]},
+				{"name":"MR-3","actions":[]}],
+				"views":[{"name":"default","jobs":[{"name":"main"},{"name":"PR-release"}]},{"name":"change-requests","jobs":[{"name":"PR-12"},{"name":"MR-3"}]}]}`)
+		case "/job/org/job/repo/job/feature%252Fx/api/json":
+			fmt.Fprint(w, `{"name":"feature%2Fx","lastBuild":{"number":5}}`)
+		case "/job/org/job/repo/job/feature%252Fx/5/api/json":
+			fmt.Fprint(w, `{"number":5,"result":"SUCCESS"}`)
+		case "/job/org/job/repo/job/new/api/json":
+			fmt.Fprint(w, `{"name":"new","lastBuild":null}`)
+		case "/job/org/job/repo/build", "/job/org/build":
+			w.WriteHeader(http.StatusCreated)
+		case "/job/org/job/repo/indexing/consoleText":
+			fmt.Fprint(w, "Checking branches...")
+		case "/job/org/computation/consoleText":
+			fmt.Fprint(w, "Scanning organization...")
+		case "/job/org/api/json":
+			fmt.Fprint(w, `{"name":"org","jobs":[{"name":"repo"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	jenkins := CreateJenkins(nil, server.URL)
+
+	assert.Equal(t, "feature%252Fx", branchJobPath("feature/x"))
+	assert.Equal(t, "100%2525", branchJobPath("100%"))
+
+	mb, err := jenkins.GetMultibranchProject("repo", "org")
+	assert.Nil(t, err)
+	assert.Equal(t, "repo", mb.GetName())
+	assert.Equal(t, SCMMetadata{DisplayName: "main", URL: "https://git/repo/tree/main", Primary: true}, mb.GetBranchJobs()[0].Metadata())
+	pr := mb.GetBranchJobs()[2]
+	assert.Equal(t, SCMMetadata{DisplayName: "Fix login", URL: "https://git/repo/pull/12", Contributor: "jdoe", ContributorEmail: "jdoe@example.com"}, pr.Metadata())
+	// Names do not matter, only what the branch API reports.
+	assert.Equal(t, []string{"main", "PR-release"}, branchJobNames(mb.GetBranches()))
+	assert.Equal(t, []string{"PR-12", "MR-3"}, branchJobNames(mb.GetPullRequests()))
+	assert.True(t, BranchJob{Actions: []branchAction{{Class: "jenkins.scm.api.metadata.ContributorMetadataAction"}}}.IsPullRequest())
+	assert.False(t, BranchJob{Name: "PR-1"}.IsPullRequest())
+
+	build, err := mb.GetLatestBuild("feature/x")
+	assert.Nil(t, err)
+	assert.Equal(t, "SUCCESS", build.GetResult())
+	_, err = mb.GetLatestBuild("new")
+	assert.Contains(t, err.Error(), "has not been built yet")
+
+	assert.Nil(t, mb.Scan())
+	log, err := mb.GetIndexingLog()
+	assert.Nil(t, err)
+	assert.Equal(t, "Checking branches...", log)
+
+	org, err := jenkins.GetOrganizationFolder("org")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"repo"}, innerJobNames(org.GetRepositories()))
+	assert.Nil(t, org.Scan())
+	log, err = org.GetScanLog()
+	assert.Nil(t, err)
+	assert.Equal(t, "Scanning organization...", log)
+	repo, err := org.GetRepository("repo")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(repo.GetBranchJobs()))
+	_, err = jenkins.GetMultibranchProject("missing")
+	assert.NotNil(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, requests, "POST /job/org/job/repo/build delay=0")
+	assert.Contains(t, requests, "POST /job/org/build delay=0")
+}
+
+func branchJobNames(jobs []BranchJob) []string {
+	names := make([]string, len(jobs))
+	for i, j := range jobs {
+		names[i] = j.Name
+	}
+	return names
+}
@@ -37,6 +37,7 @@ type JobBuild struct {
 }
 
 type InnerJob struct {
+	Class string `json:"_class"`
 	Name  string `json:"name"`
 	Url   string `json:"url"`
 	Color string `json:"color"`
@@ -69,18 +70,17 @@ type JobResponse struct {
 		IconUrl       string `json:"iconUrl"`
 		Score         int64  `json:"score"`
 	} `json:"healthReport"`
-	InQueue               bool       `json:"inQueue"`
-	KeepDependencies      bool       `json:"keepDependencies"`
-	LastBuild             JobBuild   `json:"lastBuild"`
-	LastCompletedBuild    JobBuild   `json:"lastCompletedBuild"`
-	LastFailedBuild       JobBuild   `json:"lastFailedBuild"`
-	LastStableBuild       JobBuild   `json:"lastStableBuild"`
-	LastSuccessfulBuild   JobBuild   `json:"lastSuccessfulBuild"`
-	LastUnstableBuild     JobBuild   `json:"lastUnstableBuild"`
-	LastUnsuccessfulBuild JobBuild   `json:"lastUnsuccessfulBuild"`
-	Name                  string     `json:"name"`
-	SubJobs               []InnerJob `json:"jobs"`
-	NextBuildNumber       int64      `json:"nextBuildNumber"`
+	InQueue               bool     `json:"inQueue"`
+	KeepDependencies      bool     `json:"keepDependencies"`
+	LastBuild             JobBuild `json:"lastBuild"`
+	LastCompletedBuild    JobBuild `json:"lastCompletedBuild"`
+	LastFailedBuild       JobBuild `json:"lastFailedBuild"`
+	LastStableBuild       JobBuild `json:"lastStableBuild"`
+	LastSuccessfulBuild   JobBuild `json:"lastSuccessfulBuild"`
+	LastUnstableBuild     JobBuild `json:"lastUnstableBuild"`
+	LastUnsuccessfulBuild JobBuild `json:"lastUnsuccessfulBuild"`
+	Name                  string   `json:"name"`
+	NextBuildNumber       int64    `json:"nextBuildNumber"`
 	Property              []struct {
 		ParameterDefinitions []ParameterDefinition `json:"parameterDefinitions"`
 	} `json:"property"`
@@ -91,6 +91,8 @@ type JobResponse struct {
 	Jobs             []InnerJob  `json:"jobs"`
 	PrimaryView      *ViewData   `json:"primaryView"`
 	Views            []ViewData  `json:"views"`
+	// Deprecated: SubJobs is a copy of Jobs made by Poll, use Jobs.
+	SubJobs []InnerJob `json:"-"`
 }
 
 func (j *Job) parentBase() string {
@@ -183,19 +185,23 @@ func (j *Job) GetLastCompletedBuild() (*Build, error) {
 }
 
 // Returns All Builds with Number and URL
+// The builds are fetched DefaultBuildPageSize at a time, see IterateBuilds.
 func (j *Job) GetAllBuildIds() ([]JobBuild, error) {
-	var buildsResp struct {
-		Builds []JobBuild `json:"allBuilds"`
+	builds := make([]JobBuild, 0)
+	it := j.IterateBuilds(BuildQuery{})
+	for it.Next() {
+		b := it.Build()
+		builds = append(builds, JobBuild{Number: b.Number, URL: b.URL})
 	}
-	_, err := j.Client.Requester.GetJSON(j.Base, &buildsResp, map[string]string{"tree": "allBuilds[number,url]"})
-	if err != nil {
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
-	return buildsResp.Builds, nil
+	return builds, nil
 }
 
+// GetSubJobsMetadata is the same as GetInnerJobsMetadata.
 func (j *Job) GetSubJobsMetadata() []InnerJob {
-	return j.Raw.SubJobs
+	return j.Raw.Jobs
 }
 
 func (j *Job) GetUpstreamJobsMetadata() []InnerJob {
@@ -206,16 +212,15 @@ func (j *Job) GetDownstreamJobsMetadata() []InnerJob {
 	return j.Raw.DownstreamProjects
 }
 
+// GetSubJobs is the same as GetInnerJobs for a top-level job.
 func (j *Job) GetSubJobs() ([]*Job, error) {
-	jobs := make([]*Job, len(j.Raw.SubJobs))
-	for i, job := range j.Raw.SubJobs {
-		ji, err := j.Client.GetSubJob(j.GetName(), job.Name)
-		if err != nil {
-			return nil, err
-		}
+	jobs := make([]*Job, len(j.Raw.Jobs))
+	err := j.Client.fetchAll(innerJobNames(j.Raw.Jobs), func(i int) error {
+		ji, err := j.Client.GetSubJob(j.GetName(), j.Raw.Jobs[i].Name)
 		jobs[i] = ji
-	}
-	return jobs, nil
+		return err
+	})
+	return jobs, err
 }
 
 func (j *Job) GetInnerJobsMetadata() []InnerJob {
@@ -224,26 +229,22 @@ func (j *Job) GetInnerJobsMetadata() []InnerJob {
 
 func (j *Job) GetUpstreamJobs() ([]*Job, error) {
 	jobs := make([]*Job, len(j.Raw.UpstreamProjects))
-	for i, job := range j.Raw.UpstreamProjects {
-		ji, err := j.Client.GetJob(job.Name)
-		if err != nil {
-			return nil, err
-		}
+	err := j.Client.fetchAll(innerJobNames(j.Raw.UpstreamProjects), func(i int) error {
+		ji, err := j.Client.GetJob(j.Raw.UpstreamProjects[i].Name)
 		jobs[i] = ji
-	}
-	return jobs, nil
+		return err
+	})
+	return jobs, err
 }
 
 func (j *Job) GetDownstreamJobs() ([]*Job, error) {
 	jobs := make([]*Job, len(j.Raw.DownstreamProjects))
-	for i, job := range j.Raw.DownstreamProjects {
-		ji, err := j.Client.GetJob(job.Name)
-		if err != nil {
-			return nil, err
-		}
+	err := j.Client.fetchAll(innerJobNames(j.Raw.DownstreamProjects), func(i int) error {
+		ji, err := j.Client.GetJob(j.Raw.DownstreamProjects[i].Name)
 		jobs[i] = ji
-	}
-	return jobs, nil
+		return err
+	})
+	return jobs, err
 }
 
 func (j *Job) GetInnerJob(id string) (*Job, error) {
@@ -260,14 +261,12 @@ func (j *Job) GetInnerJob(id string) (*Job, error) {
 
 func (j *Job) GetInnerJobs() ([]*Job, error) {
 	jobs := make([]*Job, len(j.Raw.Jobs))
-	for i, job := range j.Raw.Jobs {
-		ji, err := j.GetInnerJob(job.Name)
-		if err != nil {
-			return nil, err
-		}
+	err := j.Client.fetchAll(innerJobNames(j.Raw.Jobs), func(i int) error {
+		ji, err := j.GetInnerJob(j.Raw.Jobs[i].Name)
 		jobs[i] = ji
-	}
-	return jobs, nil
+		return err
+	})
+	return jobs, err
 }
 
 func (j *Job) Enable() (bool, error) {
@@ -510,19 +509,47 @@ func (j *Job) Invoke(files []string, skipIfRunning bool, params map[string]strin
 }
 
 func (j *Job) Poll() (int, error) {
-	response, err := j.Client.Requester.GetJSON(j.Base, j.Raw, nil)
+	return j.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (j *Job) PollTree(tree ...TreeField) (int, error) {
+	response, err := j.Client.Requester.GetJSON(j.Base, j.Raw, treeQueryString(tree))
 	if err != nil {
 		return 0, err
 	}
+	j.Raw.SubJobs = j.Raw.Jobs
 	return response.StatusCode, nil
 }
 
+// historyStatus maps build results to the status History used to read from the build history page,
+// the first word of the tooltip of the build icon.
+var historyStatus = map[string]string{
+	"SUCCESS":   "Success",
+	"FAILURE":   "Failed",
+	"UNSTABLE":  "Unstable",
+	"ABORTED":   "Aborted",
+	"NOT_BUILT": "Not",
+}
+
+// Returns number, status and start time (in seconds) of every build of the job.
+// The status is Success, Failed, Unstable, Aborted, Not for builds not built and In for running builds.
+// Use IterateBuilds to walk the history page by page or to filter it.
 func (j *Job) History() ([]*History, error) {
-	resp, err := j.Client.Requester.Get(j.Base+"/buildHistory/ajax", nil, nil)
-	if err != nil {
+	history := make([]*History, 0)
+	it := j.IterateBuilds(BuildQuery{})
+	for it.Next() {
+		b := it.Build()
+		status := historyStatus[b.Result]
+		if b.Building {
+			status = "In"
+		}
+		history = append(history, &History{BuildNumber: int(b.Number), BuildStatus: status, BuildTimestamp: b.Timestamp / 1000})
+	}
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
-	return parseBuildHistory(resp.Body), nil
+	return history, nil
 }
 
 // Create a new job in the folder
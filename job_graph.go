@@ -0,0 +1,449 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultJobGraphBuilds is the number of recent builds of each job read by BuildDependencyGraph
+// when JobGraphOptions.Builds is not set.
+const DefaultJobGraphBuilds = 10
+
+// JobGraphOptions tune BuildDependencyGraph.
+type JobGraphOptions struct {
+	// Builds is the number of recent builds of each job read to find triggers not
+	// declared in the job configuration, such as Pipeline build steps.
+	Builds int
+}
+
+// Kinds of JobGraphEdge, an edge can be both.
+const (
+	// JOB_EDGE_DECLARED is a trigger declared in the job configuration (upstreamProjects/downstreamProjects).
+	JOB_EDGE_DECLARED = "declared"
+	// JOB_EDGE_CAUSE is a trigger seen in the recent builds: the upstream cause of a build, or a build
+	// started by a Pipeline build step or a parameterized trigger and listed by the triggering build.
+	JOB_EDGE_CAUSE = "cause"
+)
+
+// JobGraphNode is a job of a JobGraph, Name is its full name, e.g. folder/job.
+// Err is set when the job could not be fetched, its edges are then unknown.
+type JobGraphNode struct {
+	Name  string
+	Class string
+	URL   string
+	Color string
+	Err   error
+}
+
+// JobGraphEdge is a job triggering another job.
+type JobGraphEdge struct {
+	From     string
+	To       string
+	Declared bool
+	Cause    bool
+}
+
+// JobGraph is the graph of jobs triggering each other, returned by BuildDependencyGraph.
+type JobGraph struct {
+	nodes map[string]*JobGraphNode
+	edges map[string]map[string]*JobGraphEdge
+}
+
+func newJobGraph() *JobGraph {
+	return &JobGraph{nodes: make(map[string]*JobGraphNode), edges: make(map[string]map[string]*JobGraphEdge)}
+}
+
+func (g *JobGraph) addEdge(from, to, kind string) {
+	if g.edges[from] == nil {
+		g.edges[from] = make(map[string]*JobGraphEdge)
+	}
+	e, ok := g.edges[from][to]
+	if !ok {
+		e = &JobGraphEdge{From: from, To: to}
+		g.edges[from][to] = e
+	}
+	switch kind {
+	case JOB_EDGE_DECLARED:
+		e.Declared = true
+	case JOB_EDGE_CAUSE:
+		e.Cause = true
+	}
+}
+
+// Job returns the node of a job by full name.
+func (g *JobGraph) Job(name string) (*JobGraphNode, bool) {
+	n, ok := g.nodes[name]
+	return n, ok
+}
+
+// Names returns the full names of the jobs, sorted.
+func (g *JobGraph) Names() []string {
+	names := make([]string, 0, len(g.nodes))
+	for name := range g.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Edges returns the edges sorted by source and target.
+func (g *JobGraph) Edges() []JobGraphEdge {
+	edges := make([]JobGraphEdge, 0)
+	for _, from := range g.Names() {
+		for _, to := range g.Downstream(from) {
+			edges = append(edges, *g.edges[from][to])
+		}
+	}
+	return edges
+}
+
+// Downstream returns the jobs triggered by the job, sorted.
+func (g *JobGraph) Downstream(name string) []string {
+	to := make([]string, 0, len(g.edges[name]))
+	for n := range g.edges[name] {
+		to = append(to, n)
+	}
+	sort.Strings(to)
+	return to
+}
+
+// Upstream returns the jobs triggering the job, sorted.
+func (g *JobGraph) Upstream(name string) []string {
+	from := make([]string, 0)
+	for _, n := range g.Names() {
+		if _, ok := g.edges[n][name]; ok {
+			from = append(from, n)
+		}
+	}
+	return from
+}
+
+// Failed returns the jobs that could not be fetched.
+func (g *JobGraph) Failed() []*JobGraphNode {
+	failed := make([]*JobGraphNode, 0)
+	for _, name := range g.Names() {
+		if g.nodes[name].Err != nil {
+			failed = append(failed, g.nodes[name])
+		}
+	}
+	return failed
+}
+
+// Cycles returns the groups of jobs triggering each other, each sorted, including jobs triggering themselves.
+func (g *JobGraph) Cycles() [][]string {
+	// Tarjan's strongly connected components.
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := make([]string, 0)
+	cycles := make([][]string, 0)
+	var visit func(name string)
+	visit = func(name string) {
+		index[name] = len(index)
+		low[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, to := range g.Downstream(name) {
+			if _, ok := index[to]; !ok {
+				visit(to)
+				if low[to] < low[name] {
+					low[name] = low[to]
+				}
+			} else if onStack[to] && index[to] < low[name] {
+				low[name] = index[to]
+			}
+		}
+		if low[name] != index[name] {
+			return
+		}
+		component := make([]string, 0)
+		for {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[n] = false
+			component = append(component, n)
+			if n == name {
+				break
+			}
+		}
+		if _, self := g.edges[name][name]; len(component) > 1 || self {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+	for _, name := range g.Names() {
+		if _, ok := index[name]; !ok {
+			visit(name)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// TopologicalOrder returns the jobs ordered so that every job comes after the jobs triggering it,
+// jobs without order between them sorted by name. It fails if the graph has cycles.
+func (g *JobGraph) TopologicalOrder() ([]string, error) {
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return nil, errors.New("Jobs trigger each other: " + strings.Join(cycles[0], ", "))
+	}
+	inDegree := make(map[string]int)
+	for _, e := range g.Edges() {
+		inDegree[e.To]++
+	}
+	ready := make([]string, 0)
+	for _, name := range g.Names() {
+		if inDegree[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	order := make([]string, 0, len(g.nodes))
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, to := range g.Downstream(name) {
+			inDegree[to]--
+			if inDegree[to] == 0 {
+				ready = append(ready, to)
+				sort.Strings(ready)
+			}
+		}
+	}
+	return order, nil
+}
+
+// DOT returns the graph in the Graphviz dot language. Triggers only seen in build causes are
+// dashed and jobs that could not be fetched red.
+func (g *JobGraph) DOT() string {
+	var b bytes.Buffer
+	b.WriteString("digraph jobs {\n")
+	for _, name := range g.Names() {
+		if g.nodes[name].Err != nil {
+			fmt.Fprintf(&b, "  %q [color=red, fontcolor=red];\n", name)
+		} else {
+			fmt.Fprintf(&b, "  %q;\n", name)
+		}
+	}
+	for _, e := range g.Edges() {
+		if e.Declared {
+			fmt.Fprintf(&b, "  %q -> %q;\n", e.From, e.To)
+		} else {
+			fmt.Fprintf(&b, "  %q -> %q [style=dashed];\n", e.From, e.To)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid returns the graph as a Mermaid flowchart, with the same conventions as DOT.
+func (g *JobGraph) Mermaid() string {
+	ids := make(map[string]string)
+	var b bytes.Buffer
+	b.WriteString("flowchart LR\n")
+	for i, name := range g.Names() {
+		ids[name] = "j" + strconv.Itoa(i)
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[name], strings.Replace(name, `"`, "#quot;", -1))
+	}
+	for _, e := range g.Edges() {
+		arrow := "-->"
+		if !e.Declared {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+	for _, n := range g.Failed() {
+		fmt.Fprintf(&b, "  style %s stroke:red\n", ids[n.Name])
+	}
+	return b.String()
+}
+
+type jobGraphJSON struct {
+	Jobs  []jobGraphNodeJSON `json:"jobs"`
+	Edges []jobGraphEdgeJSON `json:"edges"`
+}
+
+type jobGraphNodeJSON struct {
+	Name  string `json:"name"`
+	Class string `json:"class,omitempty"`
+	URL   string `json:"url,omitempty"`
+	Color string `json:"color,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type jobGraphEdgeJSON struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Declared bool   `json:"declared"`
+	Cause    bool   `json:"cause"`
+}
+
+// MarshalJSON exports the graph as {"jobs": [...], "edges": [{"from", "to", "declared", "cause"}]}.
+func (g *JobGraph) MarshalJSON() ([]byte, error) {
+	out := jobGraphJSON{Jobs: make([]jobGraphNodeJSON, 0), Edges: make([]jobGraphEdgeJSON, 0)}
+	for _, name := range g.Names() {
+		n := g.nodes[name]
+		node := jobGraphNodeJSON{Name: name, Class: n.Class, URL: n.URL, Color: n.Color}
+		if n.Err != nil {
+			node.Error = n.Err.Error()
+		}
+		out.Jobs = append(out.Jobs, node)
+	}
+	for _, e := range g.Edges() {
+		out.Edges = append(out.Edges, jobGraphEdgeJSON{From: e.From, To: e.To, Declared: e.Declared, Cause: e.Cause})
+	}
+	return json.Marshal(out)
+}
+
+type jobGraphResponse struct {
+	Class              string               `json:"_class"`
+	FullName           string               `json:"fullName"`
+	URL                string               `json:"url"`
+	Color              string               `json:"color"`
+	UpstreamProjects   []InnerJob           `json:"upstreamProjects"`
+	DownstreamProjects []InnerJob           `json:"downstreamProjects"`
+	Builds             []traceBuildResponse `json:"builds"`
+}
+
+// jobEndpoint turns the full name folder/job into /job/folder/job/job.
+// A matrix configuration, matrix/label=linux, is addressed as /job/matrix/label=linux.
+func jobEndpoint(fullName string) string {
+	parts := strings.Split(fullName, "/")
+	endpoint := ""
+	for i, p := range parts {
+		if i > 0 && i == len(parts)-1 && strings.Contains(p, "=") {
+			endpoint += "/" + url.PathEscape(p)
+		} else {
+			endpoint += "/job/" + url.PathEscape(p)
+		}
+	}
+	return endpoint
+}
+
+// jobFullName returns the full name of a job from its URL, folder/job for .../job/folder/job/job/.
+func (c *Client) jobFullName(absoluteURL string) string {
+	return strings.Replace(strings.TrimPrefix(c.endpointOf(absoluteURL), "/job/"), "/job/", "/", -1)
+}
+
+// fetchJobGraphNode fetches a job with the edges connecting it to other jobs.
+func (c *Client) fetchJobGraphNode(name string, builds int) (*JobGraphNode, []JobGraphEdge, error) {
+	node := &JobGraphNode{Name: name}
+	tree := []TreeField{
+		Fields("_class", "fullName", "url", "color"),
+		Tree("upstreamProjects", Fields("name", "url")),
+		Tree("downstreamProjects", Fields("name", "url")),
+		Tree("builds", Tree("actions", Tree("causes", Fields("upstreamProject")),
+			Tree("triggeredBuilds", Fields("url")),
+			Tree("downstreamBuilds", Fields("jobFullName")))).Range(0, builds),
+	}
+	resp := new(jobGraphResponse)
+	r, err := c.Requester.GetJSON(jobEndpoint(name), resp, treeQueryString(tree))
+	if err != nil {
+		return node, nil, err
+	}
+	if r.StatusCode != 200 {
+		return node, nil, errors.New("No job " + name + ": " + strconv.Itoa(r.StatusCode))
+	}
+	node.Class, node.URL, node.Color = resp.Class, resp.URL, resp.Color
+	edges := make([]JobGraphEdge, 0)
+	for _, j := range resp.UpstreamProjects {
+		edges = append(edges, JobGraphEdge{From: c.jobFullName(j.Url), To: name, Declared: true})
+	}
+	for _, j := range resp.DownstreamProjects {
+		edges = append(edges, JobGraphEdge{From: name, To: c.jobFullName(j.Url), Declared: true})
+	}
+	for _, b := range resp.Builds {
+		for _, a := range b.Actions {
+			for _, cause := range a.Causes {
+				if cause.UpstreamProject != "" {
+					edges = append(edges, JobGraphEdge{From: cause.UpstreamProject, To: name, Cause: true})
+				}
+			}
+			for _, t := range a.TriggeredBuilds {
+				if job, _, ok := parseBuildEndpoint(c.endpointOf(t.URL)); ok {
+					edges = append(edges, JobGraphEdge{From: name, To: job, Cause: true})
+				}
+			}
+			for _, d := range a.DownstreamBuilds {
+				if d.JobFullName != "" {
+					edges = append(edges, JobGraphEdge{From: name, To: d.JobFullName, Cause: true})
+				}
+			}
+		}
+	}
+	return node, edges, nil
+}
+
+// BuildDependencyGraph discovers the jobs connected to the root jobs (full names, e.g.
+// folder/job) through triggers in either direction: the upstream and downstream projects
+// Jenkins knows from the job configurations, and the last JobGraphOptions.Builds builds of
+// every job: their upstream causes and the builds they started with Pipeline build steps or
+// parameterized triggers (DownstreamBuildAction, BuildInfoExporterAction).
+// Jobs are fetched with up to Client.Concurrency parallel requests, jobs that could
+// not be fetched are kept in the graph with their Err set.
+// Example: graph, _ := jenkins.BuildDependencyGraph([]string{"release/build"}, gojenkins.JobGraphOptions{}); order, err := graph.TopologicalOrder()
+func (c *Client) BuildDependencyGraph(roots []string, opts JobGraphOptions) (*JobGraph, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("No root jobs given")
+	}
+	if opts.Builds <= 0 {
+		opts.Builds = DefaultJobGraphBuilds
+	}
+	g := newJobGraph()
+	level := make([]string, 0)
+	for _, root := range roots {
+		if _, ok := g.nodes[root]; !ok {
+			g.nodes[root] = &JobGraphNode{Name: root}
+			level = append(level, root)
+		}
+	}
+	for len(level) > 0 {
+		nodes := make([]*JobGraphNode, len(level))
+		edges := make([][]JobGraphEdge, len(level))
+		c.fetchAll(level, func(i int) error {
+			var err error
+			nodes[i], edges[i], err = c.fetchJobGraphNode(level[i], opts.Builds)
+			nodes[i].Err = err
+			return err
+		})
+		next := make([]string, 0)
+		for i, name := range level {
+			g.nodes[name] = nodes[i]
+			for _, e := range edges[i] {
+				if e.Declared {
+					g.addEdge(e.From, e.To, JOB_EDGE_DECLARED)
+				}
+				if e.Cause {
+					g.addEdge(e.From, e.To, JOB_EDGE_CAUSE)
+				}
+				for _, n := range []string{e.From, e.To} {
+					if _, ok := g.nodes[n]; !ok {
+						g.nodes[n] = &JobGraphNode{Name: n}
+						next = append(next, n)
+					}
+				}
+			}
+		}
+		level = next
+	}
+	return g, nil
+}
@@ -54,7 +54,12 @@ func (l *Label) GetNodes() []LabelNode {
 }
 
 func (l *Label) Poll() (int, error) {
-	response, err := l.Client.Requester.GetJSON(l.Base, l.Raw, nil)
+	return l.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (l *Label) PollTree(tree ...TreeField) (int, error) {
+	response, err := l.Client.Requester.GetJSON(l.Base, l.Raw, treeQueryString(tree))
 	if err != nil {
 		return 0, err
 	}
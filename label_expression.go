@@ -0,0 +1,315 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LabelExpression is a parsed Jenkins label expression such as linux && (docker || podman) && !arm.
+// Operators from lowest to highest precedence: <->, ->, ||, &&, !. Atoms containing
+// spaces or operators are quoted with double quotes.
+type LabelExpression struct {
+	source string
+	root   labelNode
+}
+
+type labelNode struct {
+	op       string // atom, !, &&, ||, -> or <->
+	atom     string
+	operands []labelNode
+}
+
+// maxSatisfiableAtoms limits the truth table built by Satisfiable.
+const maxSatisfiableAtoms = 20
+
+// ParseLabelExpression parses a label expression, an empty expression matches every node.
+func ParseLabelExpression(expr string) (*LabelExpression, error) {
+	tokens, err := tokenizeLabelExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	e := &LabelExpression{source: expr}
+	if len(tokens) == 0 {
+		return e, nil
+	}
+	p := &labelParser{tokens: tokens}
+	e.root, err = p.parseIff()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in label expression %q", p.tokens[p.pos].value, expr)
+	}
+	return e, nil
+}
+
+// Matches tells whether a node with the given labels can run the expression.
+func (e *LabelExpression) Matches(labels []string) bool {
+	if e.root.op == "" {
+		return true
+	}
+	set := make(map[string]bool, len(labels))
+	for _, l := range labels {
+		set[l] = true
+	}
+	return e.root.eval(set)
+}
+
+// Atoms returns the label names used in the expression, sorted and without duplicates.
+func (e *LabelExpression) Atoms() []string {
+	set := make(map[string]bool)
+	e.root.atoms(set)
+	atoms := make([]string, 0, len(set))
+	for a := range set {
+		atoms = append(atoms, a)
+	}
+	sort.Strings(atoms)
+	return atoms
+}
+
+// Satisfiable tells whether any set of labels matches the expression, e.g. linux && !linux is not.
+// Expressions with more than 20 distinct atoms are reported as satisfiable.
+func (e *LabelExpression) Satisfiable() bool {
+	atoms := e.Atoms()
+	if len(atoms) > maxSatisfiableAtoms {
+		return true
+	}
+	labels := make([]string, 0, len(atoms))
+	for mask := 0; mask < 1<<uint(len(atoms)); mask++ {
+		labels = labels[:0]
+		for i, a := range atoms {
+			if mask&(1<<uint(i)) != 0 {
+				labels = append(labels, a)
+			}
+		}
+		if e.Matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the expression as it was parsed.
+func (e *LabelExpression) String() string {
+	return e.source
+}
+
+func (n labelNode) eval(labels map[string]bool) bool {
+	switch n.op {
+	case "atom":
+		return labels[n.atom]
+	case "!":
+		return !n.operands[0].eval(labels)
+	case "&&":
+		return n.operands[0].eval(labels) && n.operands[1].eval(labels)
+	case "||":
+		return n.operands[0].eval(labels) || n.operands[1].eval(labels)
+	case "->":
+		return !n.operands[0].eval(labels) || n.operands[1].eval(labels)
+	case "<->":
+		return n.operands[0].eval(labels) == n.operands[1].eval(labels)
+	}
+	return false
+}
+
+func (n labelNode) atoms(set map[string]bool) {
+	if n.op == "atom" {
+		set[n.atom] = true
+	}
+	for _, o := range n.operands {
+		o.atoms(set)
+	}
+}
+
+type labelToken struct {
+	value  string
+	quoted bool
+}
+
+func (t labelToken) isOperator(op string) bool {
+	return !t.quoted && t.value == op
+}
+
+func tokenizeLabelExpression(expr string) ([]labelToken, error) {
+	tokens := make([]labelToken, 0)
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '!':
+			tokens = append(tokens, labelToken{value: string(c)})
+			i++
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"), strings.HasPrefix(expr[i:], "->"):
+			tokens = append(tokens, labelToken{value: expr[i : i+2]})
+			i += 2
+		case strings.HasPrefix(expr[i:], "<->"):
+			tokens = append(tokens, labelToken{value: "<->"})
+			i += 3
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated quote in label expression %q", expr)
+			}
+			atom, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted label %s in label expression %q", expr[i:end+1], expr)
+			}
+			tokens = append(tokens, labelToken{value: atom, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(expr) && !strings.ContainsRune(" \t\n\r()!&|<\"", rune(expr[end])) && !strings.HasPrefix(expr[end:], "->") {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected %q in label expression %q", expr[i:i+1], expr)
+			}
+			tokens = append(tokens, labelToken{value: expr[i:end], quoted: true})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type labelParser struct {
+	tokens []labelToken
+	pos    int
+}
+
+func (p *labelParser) accept(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].isOperator(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseBinary parses operands joined by op, left associative.
+func (p *labelParser) parseBinary(op string, operand func() (labelNode, error)) (labelNode, error) {
+	left, err := operand()
+	if err != nil {
+		return left, err
+	}
+	for p.accept(op) {
+		right, err := operand()
+		if err != nil {
+			return right, err
+		}
+		left = labelNode{op: op, operands: []labelNode{left, right}}
+	}
+	return left, nil
+}
+
+func (p *labelParser) parseIff() (labelNode, error) {
+	return p.parseBinary("<->", p.parseImplies)
+}
+
+func (p *labelParser) parseImplies() (labelNode, error) {
+	return p.parseBinary("->", p.parseOr)
+}
+
+func (p *labelParser) parseOr() (labelNode, error) {
+	return p.parseBinary("||", p.parseAnd)
+}
+
+func (p *labelParser) parseAnd() (labelNode, error) {
+	return p.parseBinary("&&", p.parseNot)
+}
+
+func (p *labelParser) parseNot() (labelNode, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return operand, err
+		}
+		return labelNode{op: "!", operands: []labelNode{operand}}, nil
+	}
+	if p.accept("(") {
+		inner, err := p.parseIff()
+		if err != nil {
+			return inner, err
+		}
+		if !p.accept(")") {
+			return inner, errors.New("missing closing parenthesis in label expression")
+		}
+		return inner, nil
+	}
+	if p.pos >= len(p.tokens) {
+		return labelNode{}, errors.New("unexpected end of label expression")
+	}
+	t := p.tokens[p.pos]
+	if !t.quoted {
+		return labelNode{}, fmt.Errorf("unexpected %q in label expression", t.value)
+	}
+	p.pos++
+	return labelNode{op: "atom", atom: t.value}, nil
+}
+
+// GetLabels returns the labels of the node, including the label named after the node itself.
+func (n *Node) GetLabels() []string {
+	labels := make([]string, len(n.Raw.AssignedLabels))
+	for i, l := range n.Raw.AssignedLabels {
+		labels[i] = l.Name
+	}
+	return labels
+}
+
+// LabelExpressionMatch lists the nodes that can run a label expression.
+type LabelExpressionMatch struct {
+	Expression *LabelExpression
+	Nodes      []*Node
+	// Online are the matching nodes that are currently online.
+	Online []*Node
+}
+
+// Satisfied tells whether at least one node can run the expression.
+func (m *LabelExpressionMatch) Satisfied() bool {
+	return len(m.Nodes) > 0
+}
+
+// GetNodesForLabelExpression evaluates the expression against the labels of every node.
+// An expression that no node satisfies has no Nodes.
+// Example: match, _ := jenkins.GetNodesForLabelExpression("linux && (docker || podman) && !arm")
+func (c *Client) GetNodesForLabelExpression(expr string) (*LabelExpressionMatch, error) {
+	e, err := ParseLabelExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := c.GetAllNodes(Fields("offline"), Tree("assignedLabels", Fields("name")))
+	if err != nil {
+		return nil, err
+	}
+	match := &LabelExpressionMatch{Expression: e, Nodes: make([]*Node, 0), Online: make([]*Node, 0)}
+	for _, n := range nodes {
+		if e.Matches(n.GetLabels()) {
+			match.Nodes = append(match.Nodes, n)
+			if !n.Raw.Offline {
+				match.Online = append(match.Online, n)
+			}
+		}
+	}
+	return match, nil
+}
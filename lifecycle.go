@@ -0,0 +1,144 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultReadyPollInterval is the time between two checks of WaitUntilReady
+// when Client.PollInterval is not set.
+const DefaultReadyPollInterval = 5 * time.Second
+
+func (c *Client) lifecycleAction(action string, qr map[string]string) error {
+	resp, err := c.Requester.Post("/"+action, nil, nil, qr)
+	if err != nil {
+		return err
+	}
+	// Jenkins redirects to the home page, which answers 503 while restarting.
+	if resp.StatusCode >= 400 && resp.StatusCode != 503 {
+		return errors.New(action + " failed: " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// QuietDown stops Jenkins from starting new builds, e.g. before an upgrade.
+// Running builds go on. The reason is shown to users (Jenkins 2.251 and later).
+func (c *Client) QuietDown(reason string) error {
+	var qr map[string]string
+	if reason != "" {
+		qr = map[string]string{"reason": reason}
+	}
+	return c.lifecycleAction("quietDown", qr)
+}
+
+func (c *Client) CancelQuietDown() error {
+	return c.lifecycleAction("cancelQuietDown", nil)
+}
+
+// IsQuietingDown tells whether Jenkins is preparing for shutdown.
+func (c *Client) IsQuietingDown() (bool, error) {
+	var resp struct {
+		QuietingDown bool `json:"quietingDown"`
+	}
+	_, err := c.Requester.GetJSON("/", &resp, treeQueryString([]TreeField{Fields("quietingDown")}))
+	if err != nil {
+		return false, err
+	}
+	if c.Raw != nil {
+		c.Raw.QuietingDown = resp.QuietingDown
+	}
+	return resp.QuietingDown, nil
+}
+
+// SafeRestart restarts Jenkins once the running builds finished, no new builds start in between.
+func (c *Client) SafeRestart() error {
+	c.restartSession = c.currentSession()
+	return c.lifecycleAction("safeRestart", nil)
+}
+
+// Restart restarts Jenkins immediately, running builds are aborted.
+func (c *Client) Restart() error {
+	c.restartSession = c.currentSession()
+	return c.lifecycleAction("restart", nil)
+}
+
+// SafeExit stops Jenkins once the running builds finished.
+func (c *Client) SafeExit() error {
+	return c.lifecycleAction("safeExit", nil)
+}
+
+// currentSession returns the X-Jenkins-Session of the running Jenkins, empty if it does not answer.
+func (c *Client) currentSession() string {
+	resp, err := c.Requester.GetJSON("/", new(ExecutorResponse), treeQueryString([]TreeField{Fields("quietingDown")}))
+	if err != nil || resp.StatusCode != 200 {
+		return ""
+	}
+	return resp.Header.Get("X-Jenkins-Session")
+}
+
+// WaitUntilReady waits until Jenkins answers /api/json after a restart. Without version, Jenkins has
+// restarted when it went down, i.e. a request failed or answered another status than 200, or when
+// it answers from another session (X-Jenkins-Session) than the one SafeRestart or Restart saw on
+// this client, or else than the first answer; a running Jenkins keeps answering while it quiets down.
+// With a version, Jenkins is ready when it reports this version in the X-Jenkins header, which is
+// how an upgrade is confirmed; after SafeRestart or Restart it also has to have restarted.
+// Errors while Jenkins is down are ignored, the last one is returned if ctx is done first.
+// Example: jenkins.SafeRestart(); jenkins.WaitUntilReady(ctx, "2.440.1")
+func (c *Client) WaitUntilReady(ctx context.Context, version ...string) error {
+	want := ""
+	if len(version) > 0 {
+		want = version[0]
+	}
+	var last error
+	down, session := false, c.restartSession
+	err := pollUntil(ctx, c.pollInterval(DefaultReadyPollInterval), func() (bool, error) {
+		raw := new(ExecutorResponse)
+		resp, err := c.Requester.GetJSON("/", raw, nil)
+		if err == nil && resp.StatusCode != 200 {
+			err = errors.New("Jenkins is not ready: " + strconv.Itoa(resp.StatusCode))
+		}
+		if err != nil {
+			last = err
+			down = true
+			return false, nil
+		}
+		current, running := resp.Header.Get("X-Jenkins-Session"), resp.Header.Get("X-Jenkins")
+		if session == "" && want == "" {
+			session = current
+		}
+		restarted := down || current != session
+		if want != "" && running != want {
+			last = fmt.Errorf("Jenkins runs version %s instead of %s", running, want)
+			return false, nil
+		}
+		if !restarted && (want == "" || c.restartSession != "") {
+			last = errors.New("Jenkins has not gone down for the restart yet")
+			return false, nil
+		}
+		c.Raw = raw
+		c.Version = running
+		c.restartSession = ""
+		return true, nil
+	})
+	if err != nil && last != nil {
+		return fmt.Errorf("%v: %v", err, last)
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Timescales of the load statistics.
+const (
+	TIMESCALE_SEC10 = "sec10"
+	TIMESCALE_MIN   = "min"
+	TIMESCALE_HOUR  = "hour"
+)
+
+// TimeSeries holds the samples of one timescale. Latest is the exponential moving
+// average Jenkins maintains, History the averages of past periods, newest first.
+type TimeSeries struct {
+	History []float64 `json:"history"`
+	Latest  float64   `json:"latest"`
+}
+
+// Max returns the highest value of the history and the latest average.
+func (t TimeSeries) Max() float64 {
+	max := t.Latest
+	for _, v := range t.History {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// MultiStageTimeSeries is a time series sampled every 10 seconds, every minute and every hour.
+type MultiStageTimeSeries struct {
+	Sec10 TimeSeries `json:"sec10"`
+	Min   TimeSeries `json:"min"`
+	Hour  TimeSeries `json:"hour"`
+}
+
+// Get returns the time series of TIMESCALE_SEC10, TIMESCALE_MIN or TIMESCALE_HOUR.
+func (m MultiStageTimeSeries) Get(timescale string) TimeSeries {
+	switch timescale {
+	case TIMESCALE_SEC10:
+		return m.Sec10
+	case TIMESCALE_HOUR:
+		return m.Hour
+	}
+	return m.Min
+}
+
+// LoadStatistics are the executor and queue statistics of the whole instance, a label or a node.
+// TotalQueueLength is only reported for the whole instance.
+type LoadStatistics struct {
+	Class               string               `json:"_class"`
+	AvailableExecutors  MultiStageTimeSeries `json:"availableExecutors"`
+	BusyExecutors       MultiStageTimeSeries `json:"busyExecutors"`
+	ConnectingExecutors MultiStageTimeSeries `json:"connectingExecutors"`
+	DefinedExecutors    MultiStageTimeSeries `json:"definedExecutors"`
+	IdleExecutors       MultiStageTimeSeries `json:"idleExecutors"`
+	OnlineExecutors     MultiStageTimeSeries `json:"onlineExecutors"`
+	QueueLength         MultiStageTimeSeries `json:"queueLength"`
+	TotalExecutors      MultiStageTimeSeries `json:"totalExecutors"`
+	TotalQueueLength    MultiStageTimeSeries `json:"totalQueueLength"`
+}
+
+// LoadSnapshot are the load statistics of one timescale reduced to a single value each.
+type LoadSnapshot struct {
+	Available   float64
+	Busy        float64
+	Connecting  float64
+	Defined     float64
+	Idle        float64
+	Online      float64
+	QueueLength float64
+	Total       float64
+}
+
+// Latest returns the moving averages of the timescale.
+func (s *LoadStatistics) Latest(timescale string) LoadSnapshot {
+	return s.snapshot(timescale, func(t TimeSeries) float64 { return t.Latest })
+}
+
+// Peak returns the highest values of the timescale, e.g. the busiest hour of the last days.
+func (s *LoadStatistics) Peak(timescale string) LoadSnapshot {
+	return s.snapshot(timescale, TimeSeries.Max)
+}
+
+func (s *LoadStatistics) snapshot(timescale string, value func(TimeSeries) float64) LoadSnapshot {
+	return LoadSnapshot{
+		Available:   value(s.AvailableExecutors.Get(timescale)),
+		Busy:        value(s.BusyExecutors.Get(timescale)),
+		Connecting:  value(s.ConnectingExecutors.Get(timescale)),
+		Defined:     value(s.DefinedExecutors.Get(timescale)),
+		Idle:        value(s.IdleExecutors.Get(timescale)),
+		Online:      value(s.OnlineExecutors.Get(timescale)),
+		QueueLength: value(s.QueueLength.Get(timescale)),
+		Total:       value(s.TotalExecutors.Get(timescale)),
+	}
+}
+
+// The time series are only returned with a tree query or a higher depth.
+var loadStatisticsTree = func() []TreeField {
+	series := []TreeField{
+		Tree(TIMESCALE_SEC10, Fields("history", "latest")),
+		Tree(TIMESCALE_MIN, Fields("history", "latest")),
+		Tree(TIMESCALE_HOUR, Fields("history", "latest")),
+	}
+	names := []string{"availableExecutors", "busyExecutors", "connectingExecutors", "definedExecutors",
+		"idleExecutors", "onlineExecutors", "queueLength", "totalExecutors", "totalQueueLength"}
+	tree := []TreeField{Fields("_class")}
+	for _, name := range names {
+		tree = append(tree, Tree(name, series...))
+	}
+	return tree
+}()
+
+func (c *Client) getLoadStatistics(endpoint string) (*LoadStatistics, error) {
+	stats := new(LoadStatistics)
+	resp, err := c.Requester.GetJSON(endpoint, stats, treeQueryString(loadStatisticsTree))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	return stats, nil
+}
+
+// GetOverallLoad returns the load statistics of all executors and the whole queue.
+func (c *Client) GetOverallLoad() (*LoadStatistics, error) {
+	return c.getLoadStatistics("/overallLoad")
+}
+
+// GetUnlabeledLoad returns the load statistics of the jobs without label expression.
+func (c *Client) GetUnlabeledLoad() (*LoadStatistics, error) {
+	var resp struct {
+		UnlabeledLoad LoadStatistics `json:"unlabeledLoad"`
+	}
+	_, err := c.Requester.GetJSON("/", &resp, treeQueryString([]TreeField{Tree("unlabeledLoad", loadStatisticsTree...)}))
+	if err != nil {
+		return nil, err
+	}
+	return &resp.UnlabeledLoad, nil
+}
+
+// GetLoadStatistics returns the load of the executors of the nodes with this label
+// and of the queue items waiting for them.
+func (l *Label) GetLoadStatistics() (*LoadStatistics, error) {
+	return l.Client.getLoadStatistics(l.Base + "/loadStatistics")
+}
+
+func (n *Node) GetLoadStatistics() (*LoadStatistics, error) {
+	return n.Client.getLoadStatistics(n.Base + "/loadStatistics")
+}
@@ -0,0 +1,328 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	MULTIBRANCH_PROJECT = "org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject"
+	ORGANIZATION_FOLDER = "jenkins.branch.OrganizationFolder"
+)
+
+// Fields requested when polling a multibranch project, so that the SCM metadata
+// of every branch is returned without having to query each branch job.
+var multibranchTree = []TreeField{
+	Fields("name", "displayName", "description", "url"),
+	Tree("jobs",
+		Fields("name", "displayName", "url", "color", "buildable"),
+		Tree("actions", Fields("objectDisplayName", "objectDescription", "objectUrl", "contributor", "contributorDisplayName", "contributorEmail")),
+		Tree("lastBuild", Fields("number", "url"))),
+	Tree("views", Fields("name"), Tree("jobs", Fields("name"))),
+}
+
+// changeRequestsView is the view the branch API fills with the jobs of change requests
+// (ChangeRequestSCMHead), i.e. pull requests and merge requests.
+const changeRequestsView = "change-requests"
+
+type MultibranchProject struct {
+	Raw    *MultibranchProjectResponse
+	Client *Client
+	Base   string
+}
+
+type MultibranchProjectResponse struct {
+	Class       string      `json:"_class"`
+	Name        string      `json:"name"`
+	DisplayName string      `json:"displayName"`
+	Description string      `json:"description"`
+	URL         string      `json:"url"`
+	Jobs        []BranchJob `json:"jobs"`
+	Views       []struct {
+		Name string     `json:"name"`
+		Jobs []InnerJob `json:"jobs"`
+	} `json:"views"`
+}
+
+// BranchJob is a job generated by a multibranch project for a single branch,
+// pull request or tag of the configured source.
+type BranchJob struct {
+	Class       string         `json:"_class"`
+	Name        string         `json:"name"`
+	DisplayName string         `json:"displayName"`
+	URL         string         `json:"url"`
+	Color       string         `json:"color"`
+	Buildable   bool           `json:"buildable"`
+	Actions     []branchAction `json:"actions"`
+	LastBuild   *JobBuild      `json:"lastBuild"`
+	// ChangeRequest is set when the branch API lists the job as a change request.
+	ChangeRequest bool `json:"-"`
+}
+
+type branchAction struct {
+	Class                  string `json:"_class"`
+	ObjectDisplayName      string `json:"objectDisplayName"`
+	ObjectDescription      string `json:"objectDescription"`
+	ObjectURL              string `json:"objectUrl"`
+	Contributor            string `json:"contributor"`
+	ContributorDisplayName string `json:"contributorDisplayName"`
+	ContributorEmail       string `json:"contributorEmail"`
+}
+
+// SCMMetadata is the information the SCM source reported about a branch or pull request.
+type SCMMetadata struct {
+	DisplayName            string
+	Description            string
+	URL                    string
+	Contributor            string
+	ContributorDisplayName string
+	ContributorEmail       string
+	Primary                bool
+}
+
+// Metadata collects the SCM API metadata actions attached to the branch job.
+func (b BranchJob) Metadata() SCMMetadata {
+	var m SCMMetadata
+	for _, a := range b.Actions {
+		switch a.Class {
+		case "jenkins.scm.api.metadata.ObjectMetadataAction":
+			m.DisplayName = a.ObjectDisplayName
+			m.Description = a.ObjectDescription
+			m.URL = a.ObjectURL
+		case "jenkins.scm.api.metadata.ContributorMetadataAction":
+			m.Contributor = a.Contributor
+			m.ContributorDisplayName = a.ContributorDisplayName
+			m.ContributorEmail = a.ContributorEmail
+		case "jenkins.scm.api.metadata.PrimaryInstanceMetadataAction":
+			m.Primary = true
+		}
+	}
+	return m
+}
+
+// IsPullRequest reports whether the job was created for a pull request (or merge request)
+// rather than for a branch: the branch API lists it as a change request, or the source
+// attached contributor metadata, which only change requests have.
+func (b BranchJob) IsPullRequest() bool {
+	if b.ChangeRequest {
+		return true
+	}
+	for _, a := range b.Actions {
+		if a.Class == "jenkins.scm.api.metadata.ContributorMetadataAction" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MultibranchProject) GetName() string {
+	return m.Raw.Name
+}
+
+func (m *MultibranchProject) GetDescription() string {
+	return m.Raw.Description
+}
+
+// GetBranchJobs returns every job of the project: branches, pull requests and tags.
+func (m *MultibranchProject) GetBranchJobs() []BranchJob {
+	return m.Raw.Jobs
+}
+
+// GetBranches returns only the jobs built for branches of the source.
+func (m *MultibranchProject) GetBranches() []BranchJob {
+	branches := make([]BranchJob, 0)
+	for _, b := range m.Raw.Jobs {
+		if !b.IsPullRequest() {
+			branches = append(branches, b)
+		}
+	}
+	return branches
+}
+
+// GetPullRequests returns only the jobs built for pull requests.
+func (m *MultibranchProject) GetPullRequests() []BranchJob {
+	prs := make([]BranchJob, 0)
+	for _, b := range m.Raw.Jobs {
+		if b.IsPullRequest() {
+			prs = append(prs, b)
+		}
+	}
+	return prs
+}
+
+// GetBranch returns the job for the given branch name, e.g. "feature/login".
+func (m *MultibranchProject) GetBranch(branch string) (*Job, error) {
+	job := Job{Client: m.Client, Raw: new(JobResponse), Base: m.Base + "/job/" + branchJobPath(branch)}
+	status, err := job.Poll()
+	if err != nil {
+		return nil, err
+	}
+	if status == 200 {
+		return &job, nil
+	}
+	return nil, errors.New(strconv.Itoa(status))
+}
+
+// GetLatestBuild returns the last build of the given branch.
+func (m *MultibranchProject) GetLatestBuild(branch string) (*Build, error) {
+	job, err := m.GetBranch(branch)
+	if err != nil {
+		return nil, err
+	}
+	if job.Raw.LastBuild.Number == 0 {
+		return nil, fmt.Errorf("branch %s has not been built yet", branch)
+	}
+	return job.GetLastBuild()
+}
+
+// Scan triggers a branch indexing of the project.
+func (m *MultibranchProject) Scan() error {
+	return scanComputedFolder(m.Client, m.Base)
+}
+
+// GetIndexingLog returns the console output of the last branch indexing.
+func (m *MultibranchProject) GetIndexingLog() (string, error) {
+	return computedFolderLog(m.Client, m.Base+"/indexing")
+}
+
+func (m *MultibranchProject) Poll() (int, error) {
+	response, err := m.Client.Requester.GetJSON(m.Base, m.Raw, treeQueryString(multibranchTree))
+	if err != nil {
+		return 0, err
+	}
+	changeRequests := make(map[string]bool)
+	for _, v := range m.Raw.Views {
+		if v.Name == changeRequestsView {
+			for _, j := range v.Jobs {
+				changeRequests[j.Name] = true
+			}
+		}
+	}
+	for i := range m.Raw.Jobs {
+		m.Raw.Jobs[i].ChangeRequest = changeRequests[m.Raw.Jobs[i].Name]
+	}
+	return response.StatusCode, nil
+}
+
+// OrganizationFolder is a folder that scans a GitHub organization or Bitbucket team
+// and creates a multibranch project for every matching repository.
+type OrganizationFolder struct {
+	Raw    *FolderResponse
+	Client *Client
+	Base   string
+}
+
+func (o *OrganizationFolder) GetName() string {
+	return o.Raw.Name
+}
+
+// GetRepositories returns the multibranch projects discovered by the last scan.
+func (o *OrganizationFolder) GetRepositories() []InnerJob {
+	return o.Raw.Jobs
+}
+
+func (o *OrganizationFolder) GetRepository(name string) (*MultibranchProject, error) {
+	return getMultibranchProject(o.Client, o.Base+"/job/"+name)
+}
+
+// Scan triggers a scan of the organization for new or removed repositories.
+func (o *OrganizationFolder) Scan() error {
+	return scanComputedFolder(o.Client, o.Base)
+}
+
+// GetScanLog returns the console output of the last organization scan.
+func (o *OrganizationFolder) GetScanLog() (string, error) {
+	return computedFolderLog(o.Client, o.Base+"/computation")
+}
+
+func (o *OrganizationFolder) Poll() (int, error) {
+	return o.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (o *OrganizationFolder) PollTree(tree ...TreeField) (int, error) {
+	response, err := o.Client.Requester.GetJSON(o.Base, o.Raw, treeQueryString(tree))
+	if err != nil {
+		return 0, err
+	}
+	return response.StatusCode, nil
+}
+
+func scanComputedFolder(c *Client, base string) error {
+	resp, err := c.Requester.Post(base+"/build", nil, nil, map[string]string{"delay": "0"})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		return errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+func computedFolderLog(c *Client, base string) (string, error) {
+	var log string
+	resp, err := c.Requester.Get(base+"/consoleText", &log, nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	return log, nil
+}
+
+// branchJobPath encodes a branch name the way the branch API names its jobs
+// ("feature/x" becomes the job "feature%2Fx") and escapes it for use in a URL.
+func branchJobPath(branch string) string {
+	name := strings.Replace(branch, "%", "%25", -1)
+	name = strings.Replace(name, "/", "%2F", -1)
+	return url.PathEscape(name)
+}
+
+func getMultibranchProject(c *Client, base string) (*MultibranchProject, error) {
+	mb := MultibranchProject{Client: c, Raw: new(MultibranchProjectResponse), Base: base}
+	status, err := mb.Poll()
+	if err != nil {
+		return nil, err
+	}
+	if status == 200 {
+		return &mb, nil
+	}
+	return nil, errors.New(strconv.Itoa(status))
+}
+
+// GetMultibranchProject returns a multibranch pipeline project, which can be nested in folders.
+// Example: jenkins.GetMultibranchProject("myRepo", "myOrganization")
+func (c *Client) GetMultibranchProject(id string, parents ...string) (*MultibranchProject, error) {
+	return getMultibranchProject(c, "/job/"+strings.Join(append(parents, id), "/job/"))
+}
+
+// GetOrganizationFolder returns a GitHub or Bitbucket organization folder.
+func (c *Client) GetOrganizationFolder(id string, parents ...string) (*OrganizationFolder, error) {
+	folder := OrganizationFolder{Client: c, Raw: new(FolderResponse), Base: "/job/" + strings.Join(append(parents, id), "/job/")}
+	status, err := folder.Poll()
+	if err != nil {
+		return nil, err
+	}
+	if status == 200 {
+		return &folder, nil
+	}
+	return nil, errors.New(strconv.Itoa(status))
+}
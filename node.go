@@ -15,8 +15,12 @@
 package gojenkins
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type Computers struct {
@@ -30,54 +34,65 @@ type Node struct {
 	Raw    *NodeResponse
 	Client *Client
 	Base   string
+
+	// serializes the offline state changes made through this Node
+	stateMu sync.Mutex
+}
+
+// NodeExecutor is an executor of a node, CurrentExecutable is empty when it is idle.
+type NodeExecutor struct {
+	Number            int  `json:"number"`
+	Idle              bool `json:"idle"`
+	LikelyStuck       bool `json:"likelyStuck"`
+	Progress          int  `json:"progress"`
+	CurrentExecutable struct {
+		Class             string `json:"_class"`
+		Number            int    `json:"number"`
+		URL               string `json:"url"`
+		FullDisplayName   string `json:"fullDisplayName"`
+		Timestamp         int64  `json:"timestamp"`
+		EstimatedDuration int64  `json:"estimatedDuration"`
+		SubBuilds         []struct {
+			Abort             bool        `json:"abort"`
+			Build             interface{} `json:"build"`
+			BuildNumber       int         `json:"buildNumber"`
+			Duration          string      `json:"duration"`
+			Icon              string      `json:"icon"`
+			JobName           string      `json:"jobName"`
+			ParentBuildNumber int         `json:"parentBuildNumber"`
+			ParentJobName     string      `json:"parentJobName"`
+			PhaseName         string      `json:"phaseName"`
+			Result            string      `json:"result"`
+			Retry             bool        `json:"retry"`
+			URL               string      `json:"url"`
+		} `json:"subBuilds"`
+	} `json:"currentExecutable"`
+}
+
+// NodeLabel is a label assigned to a node.
+type NodeLabel struct {
+	Name string `json:"name"`
 }
 
 type NodeResponse struct {
-	Actions     []interface{} `json:"actions"`
-	DisplayName string        `json:"displayName"`
-	Executors   []struct {
-		CurrentExecutable struct {
-			Number    int    `json:"number"`
-			URL       string `json:"url"`
-			SubBuilds []struct {
-				Abort             bool        `json:"abort"`
-				Build             interface{} `json:"build"`
-				BuildNumber       int         `json:"buildNumber"`
-				Duration          string      `json:"duration"`
-				Icon              string      `json:"icon"`
-				JobName           string      `json:"jobName"`
-				ParentBuildNumber int         `json:"parentBuildNumber"`
-				ParentJobName     string      `json:"parentJobName"`
-				PhaseName         string      `json:"phaseName"`
-				Result            string      `json:"result"`
-				Retry             bool        `json:"retry"`
-				URL               string      `json:"url"`
-			} `json:"subBuilds"`
-		} `json:"currentExecutable"`
-	} `json:"executors"`
-	Icon                string   `json:"icon"`
-	IconClassName       string   `json:"iconClassName"`
-	Idle                bool     `json:"idle"`
-	JnlpAgent           bool     `json:"jnlpAgent"`
-	LaunchSupported     bool     `json:"launchSupported"`
-	LoadStatistics      struct{} `json:"loadStatistics"`
-	ManualLaunchAllowed bool     `json:"manualLaunchAllowed"`
-	MonitorData         struct {
-		Hudson_NodeMonitors_ArchitectureMonitor interface{} `json:"hudson.node_monitors.ArchitectureMonitor"`
-		Hudson_NodeMonitors_ClockMonitor        interface{} `json:"hudson.node_monitors.ClockMonitor"`
-		Hudson_NodeMonitors_DiskSpaceMonitor    interface{} `json:"hudson.node_monitors.DiskSpaceMonitor"`
-		Hudson_NodeMonitors_ResponseTimeMonitor struct {
-			Average int64 `json:"average"`
-		} `json:"hudson.node_monitors.ResponseTimeMonitor"`
-		Hudson_NodeMonitors_SwapSpaceMonitor      interface{} `json:"hudson.node_monitors.SwapSpaceMonitor"`
-		Hudson_NodeMonitors_TemporarySpaceMonitor interface{} `json:"hudson.node_monitors.TemporarySpaceMonitor"`
-	} `json:"monitorData"`
-	NumExecutors       int64         `json:"numExecutors"`
-	Offline            bool          `json:"offline"`
-	OfflineCause       struct{}      `json:"offlineCause"`
-	OfflineCauseReason string        `json:"offlineCauseReason"`
-	OneOffExecutors    []interface{} `json:"oneOffExecutors"`
-	TemporarilyOffline bool          `json:"temporarilyOffline"`
+	Actions             []interface{}   `json:"actions"`
+	AssignedLabels      []NodeLabel     `json:"assignedLabels"`
+	DisplayName         string          `json:"displayName"`
+	Executors           []NodeExecutor  `json:"executors"`
+	Icon                string          `json:"icon"`
+	IconClassName       string          `json:"iconClassName"`
+	Idle                bool            `json:"idle"`
+	JnlpAgent           bool            `json:"jnlpAgent"`
+	LaunchSupported     bool            `json:"launchSupported"`
+	LoadStatistics      LoadStatistics  `json:"loadStatistics"`
+	ManualLaunchAllowed bool            `json:"manualLaunchAllowed"`
+	MonitorData         NodeMonitorData `json:"monitorData"`
+	NumExecutors        int64           `json:"numExecutors"`
+	Offline             bool            `json:"offline"`
+	OfflineCause        *OfflineCause   `json:"offlineCause"`
+	OfflineCauseReason  string          `json:"offlineCauseReason"`
+	OneOffExecutors     []NodeExecutor  `json:"oneOffExecutors"`
+	TemporarilyOffline  bool            `json:"temporarilyOffline"`
 }
 
 func (n *Node) Info() (*NodeResponse, error) {
@@ -132,29 +147,90 @@ func (n *Node) IsJnlpAgent() (bool, error) {
 	return n.Raw.JnlpAgent, nil
 }
 
-func (n *Node) SetOnline() (bool, error) {
-	_, err := n.Poll()
+type NodeState string
+
+const (
+	// The agent is connected and accepts builds.
+	NODE_ONLINE NodeState = "online"
+	// The agent was taken offline on purpose, it may still run builds started before.
+	NODE_TEMPORARILY_OFFLINE NodeState = "temporarilyOffline"
+	// The agent is disconnected.
+	NODE_OFFLINE NodeState = "offline"
+)
+
+// DefaultDrainPollInterval is how often Drain and WaitUntilOnline check the node
+// when Client.PollInterval is not set.
+const DefaultDrainPollInterval = 5 * time.Second
+
+var executorFields = []TreeField{
+	Fields("number", "idle", "likelyStuck", "progress"),
+	Tree("currentExecutable", Fields("number", "url", "fullDisplayName", "timestamp", "estimatedDuration")),
+}
+
+var nodeStateTree = []TreeField{
+	Fields("displayName", "idle", "offline", "temporarilyOffline", "offlineCauseReason"),
+	Tree("executors", executorFields...),
+	Tree("oneOffExecutors", executorFields...),
+}
+
+// State polls the node and returns its current state.
+func (n *Node) State() (NodeState, error) {
+	if err := n.pollState(); err != nil {
+		return "", err
+	}
+	return n.state(), nil
+}
 
+// pollState refreshes the offline flags and executors of the node. The executors are
+// reset first, an idle executor has a null currentExecutable which would not clear the old one.
+func (n *Node) pollState() error {
+	n.Raw.Executors = nil
+	n.Raw.OneOffExecutors = nil
+	_, err := n.PollTree(nodeStateTree...)
+	return err
+}
+
+func (n *Node) state() NodeState {
+	switch {
+	case n.Raw.TemporarilyOffline:
+		return NODE_TEMPORARILY_OFFLINE
+	case n.Raw.Offline:
+		return NODE_OFFLINE
+	}
+	return NODE_ONLINE
+}
+
+func (n *Node) SetOnline() (bool, error) {
+	state, err := n.State()
 	if err != nil {
 		return false, err
 	}
 
-	if n.Raw.Offline && !n.Raw.TemporarilyOffline {
+	if state == NODE_OFFLINE {
 		return false, errors.New("Node is Permanently offline, can't bring it up")
 	}
 
-	if n.Raw.Offline && n.Raw.TemporarilyOffline {
-		return n.ToggleTemporarilyOffline()
+	if state == NODE_TEMPORARILY_OFFLINE {
+		if err := n.setTemporarilyOffline(false, ""); err != nil {
+			return false, err
+		}
 	}
 
 	return true, nil
 }
 
 func (n *Node) SetOffline(options ...interface{}) (bool, error) {
-	if !n.Raw.Offline {
-		return n.ToggleTemporarilyOffline(options...)
+	state, err := n.State()
+	if err != nil {
+		return false, err
 	}
-	return false, errors.New("Node already Offline")
+	if state != NODE_ONLINE {
+		return false, errors.New("Node already Offline")
+	}
+	if err := n.setTemporarilyOffline(true, offlineMessage(options)); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (n *Node) ToggleTemporarilyOffline(options ...interface{}) (bool, error) {
@@ -162,26 +238,109 @@ func (n *Node) ToggleTemporarilyOffline(options ...interface{}) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	qr := map[string]string{"offlineMessage": "requested from gojenkins"}
+	if err := n.setTemporarilyOffline(!state_before, offlineMessage(options)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func offlineMessage(options []interface{}) string {
 	if len(options) > 0 {
-		qr["offlineMessage"] = options[0].(string)
+		if msg, ok := options[0].(string); ok {
+			return msg
+		}
 	}
-	_, err = n.Client.Requester.Post(n.Base+"/toggleOffline", nil, nil, qr)
-	if err != nil {
-		return false, err
+	return "requested from gojenkins"
+}
+
+// setTemporarilyOffline brings the node in the wanted state. Jenkins only offers a toggle,
+// so the state is checked right before toggling and verified afterwards; when somebody else
+// changed the state in between the toggle is repeated.
+func (n *Node) setTemporarilyOffline(offline bool, message string) error {
+	n.stateMu.Lock()
+	defer n.stateMu.Unlock()
+
+	for attempt := 0; attempt < 3; attempt++ {
+		if err := n.pollState(); err != nil {
+			return err
+		}
+		if n.Raw.TemporarilyOffline == offline {
+			return nil
+		}
+		qr := map[string]string{"offlineMessage": message}
+		if _, err := n.Client.Requester.Post(n.Base+"/toggleOffline", nil, nil, qr); err != nil {
+			return err
+		}
+		if err := n.pollState(); err != nil {
+			return err
+		}
+		if n.Raw.TemporarilyOffline == offline {
+			return nil
+		}
 	}
-	new_state, err := n.IsTemporarilyOffline()
-	if err != nil {
-		return false, err
+	return errors.New("Node state not changed")
+}
+
+// Drain takes the node temporarily offline, so it accepts no new builds, and waits until
+// the builds running on it have finished. When ctx is done before, the remaining builds
+// are aborted and an error is returned. The node stays offline in both cases.
+// Example: ctx, cancel := context.WithTimeout(context.Background(), time.Hour); node.Drain(ctx, "kernel upgrade")
+func (n *Node) Drain(ctx context.Context, reason string) error {
+	if err := n.setTemporarilyOffline(true, reason); err != nil {
+		return err
+	}
+	err := pollUntil(ctx, n.Client.pollInterval(DefaultDrainPollInterval), func() (bool, error) {
+		if err := n.pollState(); err != nil {
+			return false, err
+		}
+		return len(n.runningExecutables()) == 0, nil
+	})
+	if err == nil || err != ctx.Err() {
+		return err
 	}
-	if state_before == new_state {
-		return false, errors.New("Node state not changed")
+
+	// ctx is done, use a fresh state to abort what is still running
+	if err := n.pollState(); err != nil {
+		return err
 	}
-	return true, nil
+	running := n.runningExecutables()
+	for _, u := range running {
+		if _, err := n.Client.Requester.Post(n.Client.endpointOf(u)+"/stop", nil, nil, nil); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("aborted %d builds still running on %s: %v", len(running), n.GetName(), ctx.Err())
+}
+
+// WaitUntilOnline waits until the node is connected and accepts builds, e.g. after a reboot.
+func (n *Node) WaitUntilOnline(ctx context.Context) error {
+	return pollUntil(ctx, n.Client.pollInterval(DefaultDrainPollInterval), func() (bool, error) {
+		state, err := n.State()
+		if err != nil {
+			return false, err
+		}
+		return state == NODE_ONLINE, nil
+	})
+}
+
+// runningExecutables returns the URLs of the builds running on the polled node.
+func (n *Node) runningExecutables() []string {
+	urls := make([]string, 0)
+	for _, e := range append(n.Raw.Executors, n.Raw.OneOffExecutors...) {
+		if e.CurrentExecutable.URL != "" {
+			urls = append(urls, e.CurrentExecutable.URL)
+		}
+	}
+	return urls
 }
 
 func (n *Node) Poll() (int, error) {
-	response, err := n.Client.Requester.GetJSON(n.Base, n.Raw, nil)
+	return n.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (n *Node) PollTree(tree ...TreeField) (int, error) {
+	response, err := n.Client.Requester.GetJSON(n.Base, n.Raw, treeQueryString(tree))
 	if err != nil {
 		return 0, err
 	}
@@ -231,10 +390,11 @@ func (n *Node) GetLogText() (string, error) {
 }
 
 // Create a new Node
-// Can be JNLPLauncher or SSHLauncher
+// Can be JNLPLauncher, SSHLauncher or CommandLauncher
 // Example : jenkins.CreateNode("nodeName", 1, "Description", "/var/lib/jenkins", "jdk8 docker", map[string]string{"method": "JNLPLauncher"})
 // By Default JNLPLauncher is created
 // Multiple labels should be separated by blanks
+// The credentialsId of an SSHLauncher can be checked beforehand with FindCredential
 func (c *Client) CreateNode(name string, numExecutors int, description string, remoteFS string, label string, options ...interface{}) (*Node, error) {
 	params := map[string]string{"method": "JNLPLauncher"}
 
@@ -269,6 +429,12 @@ func (c *Client) CreateNode(name string, numExecutors int, description string, r
 			"launchTimeoutSeconds": params["launchTimeoutSeconds"],
 			"type":                 "hudson.slaves.DumbSlave",
 			"stapler-class-bag":    "true"}
+	case "CommandLauncher":
+		launcher = map[string]string{
+			"stapler-class": "hudson.slaves.CommandLauncher",
+			"$class":        "hudson.slaves.CommandLauncher",
+			"command":       params["command"],
+		}
 	default:
 		return nil, errors.New("launcher method not supported")
 	}
@@ -0,0 +1,240 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	JNLP_LAUNCHER    = "hudson.slaves.JNLPLauncher"
+	SSH_LAUNCHER     = "hudson.plugins.sshslaves.SSHLauncher"
+	COMMAND_LAUNCHER = "hudson.slaves.CommandLauncher"
+
+	RETENTION_ALWAYS = "hudson.slaves.RetentionStrategy$Always"
+	RETENTION_DEMAND = "hudson.slaves.RetentionStrategy$Demand"
+)
+
+// xmlElement keeps an element of a config.xml that has no typed field,
+// so that updating a config does not drop settings of unknown plugins.
+type xmlElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// xmlConfig is a config.xml decoded element by element, so that it can be changed
+// and written back with its elements in their original order.
+type xmlConfig struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Elements []xmlElement `xml:",any"`
+}
+
+// NodeConfig is the configuration of an agent as stored in /computer/{name}/config.xml.
+// Elements without a typed field are kept in Extra and written back unchanged.
+type NodeConfig struct {
+	XMLName           xml.Name
+	Name              string            `xml:"name"`
+	Description       string            `xml:"description"`
+	RemoteFS          string            `xml:"remoteFS"`
+	NumExecutors      int               `xml:"numExecutors"`
+	Mode              string            `xml:"mode"`
+	RetentionStrategy RetentionStrategy `xml:"retentionStrategy"`
+	Launcher          NodeLauncher      `xml:"launcher"`
+	Label             string            `xml:"label"`
+	NodeProperties    NodeProperties    `xml:"nodeProperties"`
+	Extra             []xmlElement      `xml:",any"`
+}
+
+// RetentionStrategy decides when Jenkins keeps the agent connected,
+// e.g. RETENTION_ALWAYS or RETENTION_DEMAND with its delays in minutes.
+type RetentionStrategy struct {
+	Class         string       `xml:"class,attr"`
+	InDemandDelay *int         `xml:"inDemandDelay,omitempty"`
+	IdleDelay     *int         `xml:"idleDelay,omitempty"`
+	Extra         []xmlElement `xml:",any"`
+}
+
+// NodeLauncher holds the settings of all supported launchers, only the fields
+// of the launcher named by Class are used: JNLP_LAUNCHER, SSH_LAUNCHER or COMMAND_LAUNCHER.
+type NodeLauncher struct {
+	Class  string `xml:"class,attr"`
+	Plugin string `xml:"plugin,attr,omitempty"`
+
+	// JNLP_LAUNCHER
+	Tunnel    string `xml:"tunnel,omitempty"`
+	Vmargs    string `xml:"vmargs,omitempty"`
+	WebSocket *bool  `xml:"webSocket,omitempty"`
+
+	// COMMAND_LAUNCHER
+	AgentCommand string `xml:"agentCommand,omitempty"`
+
+	// SSH_LAUNCHER
+	Host                 string `xml:"host,omitempty"`
+	Port                 int    `xml:"port,omitempty"`
+	CredentialsID        string `xml:"credentialsId,omitempty"`
+	JavaPath             string `xml:"javaPath,omitempty"`
+	JvmOptions           string `xml:"jvmOptions,omitempty"`
+	PrefixStartSlaveCmd  string `xml:"prefixStartSlaveCmd,omitempty"`
+	SuffixStartSlaveCmd  string `xml:"suffixStartSlaveCmd,omitempty"`
+	LaunchTimeoutSeconds *int   `xml:"launchTimeoutSeconds,omitempty"`
+	MaxNumRetries        *int   `xml:"maxNumRetries,omitempty"`
+	RetryWaitTime        *int   `xml:"retryWaitTime,omitempty"`
+
+	Extra []xmlElement `xml:",any"`
+}
+
+// NodeProperties are the environment variables and tool locations of the agent.
+// Other node properties are kept in Extra.
+type NodeProperties struct {
+	Env           EnvVars        `xml:"hudson.slaves.EnvironmentVariablesNodeProperty>envVars,omitempty"`
+	ToolLocations []ToolLocation `xml:"hudson.tools.ToolLocationNodeProperty>locations>hudson.tools.ToolLocationNodeProperty_-ToolLocation,omitempty"`
+	Extra         []xmlElement   `xml:",any"`
+}
+
+// ToolLocation overrides the home of a tool installation on the agent,
+// e.g. {Type: "hudson.model.JDK$DescriptorImpl", Name: "jdk8", Home: "/opt/jdk8"}.
+type ToolLocation struct {
+	Type string `xml:"type"`
+	Name string `xml:"name"`
+	Home string `xml:"home"`
+}
+
+// EnvVars are environment variables, stored by Jenkins as a serialized TreeMap.
+type EnvVars map[string]string
+
+type envVarsTreeMap struct {
+	Strings []string `xml:"tree-map>string"`
+}
+
+func (e *EnvVars) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var tm envVarsTreeMap
+	if err := d.DecodeElement(&tm, &start); err != nil {
+		return err
+	}
+	if len(tm.Strings)%2 != 0 {
+		return errors.New("environment variables have a key without value")
+	}
+	*e = make(EnvVars)
+	for i := 0; i < len(tm.Strings); i += 2 {
+		(*e)[tm.Strings[i]] = tm.Strings[i+1]
+	}
+	return nil
+}
+
+func (e EnvVars) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.WriteString(`<unserializable-parents/><tree-map><default><comparator class="java.lang.String$CaseInsensitiveComparator"/></default>`)
+	b.WriteString("<int>" + strconv.Itoa(len(keys)) + "</int>")
+	for _, k := range keys {
+		for _, s := range []string{k, e[k]} {
+			b.WriteString("<string>")
+			xml.EscapeText(&b, []byte(s))
+			b.WriteString("</string>")
+		}
+	}
+	b.WriteString("</tree-map>")
+
+	start.Attr = []xml.Attr{{Name: xml.Name{Local: "serialization"}, Value: "custom"}}
+	return enc.EncodeElement(struct {
+		Inner string `xml:",innerxml"`
+	}{b.String()}, start)
+}
+
+// GetLabels returns the labels of the agent.
+func (c *NodeConfig) GetLabels() []string {
+	return strings.Fields(c.Label)
+}
+
+func (n *Node) GetConfigXML() (string, error) {
+	var data string
+	_, err := n.Client.Requester.GetXML(n.Base+"/config.xml", &data, nil)
+	if err != nil {
+		return "", err
+	}
+	return data, nil
+}
+
+func (n *Node) UpdateConfigXML(config string) error {
+	resp, err := n.Client.Requester.PostXML(n.Base+"/config.xml", config, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == 200 {
+		n.Poll()
+		return nil
+	}
+	return errors.New(strconv.Itoa(resp.StatusCode))
+}
+
+// GetConfig returns the typed configuration of the agent.
+func (n *Node) GetConfig() (*NodeConfig, error) {
+	data, err := n.GetConfigXML()
+	if err != nil {
+		return nil, err
+	}
+	config := new(NodeConfig)
+	if err := unmarshalXML(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// UpdateConfig replaces the configuration of the agent.
+// Use a config returned by GetConfig so that settings without typed fields are kept.
+func (n *Node) UpdateConfig(config *NodeConfig) error {
+	if config.XMLName.Local == "" {
+		config.XMLName.Local = "slave"
+	}
+	data, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return n.UpdateConfigXML(string(data))
+}
+
+// SetLabels replaces the labels of the agent.
+func (n *Node) SetLabels(labels ...string) error {
+	config, err := n.GetConfig()
+	if err != nil {
+		return err
+	}
+	config.Label = strings.Join(labels, " ")
+	return n.UpdateConfig(config)
+}
+
+func (n *Node) SetNumExecutors(numExecutors int) error {
+	if numExecutors < 1 {
+		return errors.New("a node needs at least one executor")
+	}
+	config, err := n.GetConfig()
+	if err != nil {
+		return err
+	}
+	config.NumExecutors = numExecutors
+	return n.UpdateConfig(config)
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"fmt"
+	"time"
+)
+
+// NodeMonitorData is what the node monitors of Jenkins report about a node.
+// The monitors report nothing (nil) while the node is offline.
+type NodeMonitorData struct {
+	Hudson_NodeMonitors_ArchitectureMonitor   string           `json:"hudson.node_monitors.ArchitectureMonitor"`
+	Hudson_NodeMonitors_ClockMonitor          *ClockDifference `json:"hudson.node_monitors.ClockMonitor"`
+	Hudson_NodeMonitors_DiskSpaceMonitor      *DiskSpace       `json:"hudson.node_monitors.DiskSpaceMonitor"`
+	Hudson_NodeMonitors_ResponseTimeMonitor   ResponseTime     `json:"hudson.node_monitors.ResponseTimeMonitor"`
+	Hudson_NodeMonitors_SwapSpaceMonitor      *MemoryUsage     `json:"hudson.node_monitors.SwapSpaceMonitor"`
+	Hudson_NodeMonitors_TemporarySpaceMonitor *DiskSpace       `json:"hudson.node_monitors.TemporarySpaceMonitor"`
+}
+
+// DiskSpace is the free space of the node's workspace root or temporary directory.
+type DiskSpace struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// MemoryUsage sizes are in bytes, -1 when the node could not determine them.
+type MemoryUsage struct {
+	AvailablePhysicalMemory int64 `json:"availablePhysicalMemory"`
+	AvailableSwapSpace      int64 `json:"availableSwapSpace"`
+	TotalPhysicalMemory     int64 `json:"totalPhysicalMemory"`
+	TotalSwapSpace          int64 `json:"totalSwapSpace"`
+}
+
+// ClockDifference is the difference between the node's clock and the controller's, in milliseconds.
+type ClockDifference struct {
+	Diff int64 `json:"diff"`
+}
+
+// ResponseTime is the average round trip time to the node, in milliseconds.
+type ResponseTime struct {
+	Average   int64 `json:"average"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// OfflineCause tells why a node is offline, e.g. taken offline by a user or a lost connection.
+type OfflineCause struct {
+	Class       string `json:"_class"`
+	Description string `json:"description"`
+	Message     string `json:"message"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+func (n *Node) GetMonitorData() NodeMonitorData {
+	return n.Raw.MonitorData
+}
+
+func (n *Node) GetOfflineCause() *OfflineCause {
+	return n.Raw.OfflineCause
+}
+
+// HealthThresholds are the limits used by NodeHealthReport, zero values are not checked.
+type HealthThresholds struct {
+	MinFreeDiskSpace   int64 // bytes
+	MinFreeTempSpace   int64 // bytes
+	MinFreeSwapSpace   int64 // bytes
+	MaxClockDifference time.Duration
+	MaxResponseTime    time.Duration
+}
+
+// DefaultHealthThresholds match the limits of the Jenkins node monitors.
+var DefaultHealthThresholds = HealthThresholds{
+	MinFreeDiskSpace:   1 << 30,
+	MinFreeTempSpace:   1 << 30,
+	MaxClockDifference: 5 * time.Second,
+	MaxResponseTime:    5 * time.Second,
+}
+
+// NodeHealth lists the problems found on one node.
+type NodeHealth struct {
+	Node         string
+	Offline      bool
+	OfflineCause *OfflineCause
+	Monitor      NodeMonitorData
+	Problems     []string
+}
+
+func (h NodeHealth) Healthy() bool {
+	return len(h.Problems) == 0
+}
+
+type NodeHealthReport struct {
+	Nodes []NodeHealth
+}
+
+// Unhealthy returns the nodes with at least one problem.
+func (r *NodeHealthReport) Unhealthy() []NodeHealth {
+	nodes := make([]NodeHealth, 0)
+	for _, n := range r.Nodes {
+		if !n.Healthy() {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// NodeHealthReport checks the monitor data of every node against the thresholds.
+// Example: report, _ := jenkins.NodeHealthReport(gojenkins.DefaultHealthThresholds)
+func (c *Client) NodeHealthReport(thresholds HealthThresholds) (*NodeHealthReport, error) {
+	nodes, err := c.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	report := &NodeHealthReport{Nodes: make([]NodeHealth, len(nodes))}
+	for i, n := range nodes {
+		report.Nodes[i] = checkNodeHealth(n.Raw, thresholds)
+	}
+	return report, nil
+}
+
+func checkNodeHealth(n *NodeResponse, t HealthThresholds) NodeHealth {
+	h := NodeHealth{Node: n.DisplayName, Offline: n.Offline, OfflineCause: n.OfflineCause, Monitor: n.MonitorData}
+	if n.Offline {
+		reason := n.OfflineCauseReason
+		if reason == "" && n.OfflineCause != nil {
+			reason = n.OfflineCause.Description
+		}
+		h.Problems = append(h.Problems, "offline: "+reason)
+	}
+
+	m := n.MonitorData
+	if disk := m.Hudson_NodeMonitors_DiskSpaceMonitor; disk != nil && t.MinFreeDiskSpace > 0 && disk.Size < t.MinFreeDiskSpace {
+		h.Problems = append(h.Problems, fmt.Sprintf("free disk space %s at %s is below %s", formatBytes(disk.Size), disk.Path, formatBytes(t.MinFreeDiskSpace)))
+	}
+	if tmp := m.Hudson_NodeMonitors_TemporarySpaceMonitor; tmp != nil && t.MinFreeTempSpace > 0 && tmp.Size < t.MinFreeTempSpace {
+		h.Problems = append(h.Problems, fmt.Sprintf("free temp space %s at %s is below %s", formatBytes(tmp.Size), tmp.Path, formatBytes(t.MinFreeTempSpace)))
+	}
+	if swap := m.Hudson_NodeMonitors_SwapSpaceMonitor; swap != nil && t.MinFreeSwapSpace > 0 && swap.AvailableSwapSpace >= 0 && swap.AvailableSwapSpace < t.MinFreeSwapSpace {
+		h.Problems = append(h.Problems, fmt.Sprintf("free swap space %s is below %s", formatBytes(swap.AvailableSwapSpace), formatBytes(t.MinFreeSwapSpace)))
+	}
+	if clock := m.Hudson_NodeMonitors_ClockMonitor; clock != nil && t.MaxClockDifference > 0 {
+		diff := time.Duration(clock.Diff) * time.Millisecond
+		if diff > t.MaxClockDifference || -diff > t.MaxClockDifference {
+			h.Problems = append(h.Problems, fmt.Sprintf("clock differs by %s", diff))
+		}
+	}
+	if avg := time.Duration(m.Hudson_NodeMonitors_ResponseTimeMonitor.Average) * time.Millisecond; t.MaxResponseTime > 0 && avg > t.MaxResponseTime {
+		h.Problems = append(h.Problems, fmt.Sprintf("average response time %s is above %s", avg, t.MaxResponseTime))
+	}
+	return h
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
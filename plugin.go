@@ -29,25 +29,27 @@ type PluginResponse struct {
 	Plugins []Plugin `json:"plugins"`
 }
 
+type PluginDependency struct {
+	Optional  bool   `json:"optional"`
+	ShortName string `json:"shortName"`
+	Version   string `json:"version"`
+}
+
 type Plugin struct {
-	Active        bool        `json:"active"`
-	BackupVersion interface{} `json:"backupVersion"`
-	Bundled       bool        `json:"bundled"`
-	Deleted       bool        `json:"deleted"`
-	Dependencies  []struct {
-		Optional  string `json:"optional"`
-		ShortName string `json:"shortname"`
-		Version   string `json:"version"`
-	} `json:"dependencies"`
-	Downgradable        bool   `json:"downgradable"`
-	Enabled             bool   `json:"enabled"`
-	HasUpdate           bool   `json:"hasUpdate"`
-	LongName            string `json:"longName"`
-	Pinned              bool   `json:"pinned"`
-	ShortName           string `json:"shortName"`
-	SupportsDynamicLoad string `json:"supportsDynamicLoad"`
-	URL                 string `json:"url"`
-	Version             string `json:"version"`
+	Active              bool               `json:"active"`
+	BackupVersion       interface{}        `json:"backupVersion"`
+	Bundled             bool               `json:"bundled"`
+	Deleted             bool               `json:"deleted"`
+	Dependencies        []PluginDependency `json:"dependencies"`
+	Downgradable        bool               `json:"downgradable"`
+	Enabled             bool               `json:"enabled"`
+	HasUpdate           bool               `json:"hasUpdate"`
+	LongName            string             `json:"longName"`
+	Pinned              bool               `json:"pinned"`
+	ShortName           string             `json:"shortName"`
+	SupportsDynamicLoad string             `json:"supportsDynamicLoad"`
+	URL                 string             `json:"url"`
+	Version             string             `json:"version"`
 }
 
 func (p *Plugins) Count() int {
@@ -95,4 +97,3 @@ func (c *Client) GetPlugins(depth int) (*Plugins, error) {
 	}
 	return &p, nil
 }
-
@@ -0,0 +1,415 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CompareVersions compares two plugin or core versions like Jenkins does, returning
+// -1, 0 or 1. Numeric parts compare as numbers, trailing zero parts are ignored and a
+// qualifier sorts before the release it qualifies: 1.0-beta-2 < 1.0 = 1.0.0 < 1.0.1 < 1.10.
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		switch {
+		case i >= len(pa):
+			return -extraPartsSign(pb[i:])
+		case i >= len(pb):
+			return extraPartsSign(pa[i:])
+		}
+		if c := compareVersionPart(pa[i], pb[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == '.' || r == '-' || r == '_' || r == '+' || unicode.IsSpace(r)
+	})
+}
+
+// extraPartsSign tells how a version with the extra parts compares to the version without them.
+func extraPartsSign(parts []string) int {
+	for _, p := range parts {
+		if n, err := strconv.ParseInt(p, 10, 64); err != nil || n != 0 {
+			return qualifierSign(p)
+		}
+	}
+	return 0
+}
+
+// qualifierSign tells how a version with the extra part p compares to the version without it.
+func qualifierSign(p string) int {
+	if _, err := strconv.ParseInt(p, 10, 64); err == nil {
+		return 1
+	}
+	return -1
+}
+
+func compareVersionPart(a, b string) int {
+	na, errA := strconv.ParseInt(a, 10, 64)
+	nb, errB := strconv.ParseInt(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if na < nb {
+			return -1
+		}
+		if na > nb {
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return 1
+	case errB == nil:
+		return -1
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// Kinds of PluginProblem.
+const (
+	PLUGIN_MISSING_DEPENDENCY  = "missing dependency"
+	PLUGIN_VERSION_CONFLICT    = "version conflict"
+	PLUGIN_DISABLED_DEPENDENCY = "disabled dependency"
+)
+
+// PluginProblem is a dependency of an enabled plugin that keeps it from loading.
+type PluginProblem struct {
+	Kind       string
+	Plugin     string
+	Dependency string
+	Required   string // version required by Plugin
+	Installed  string // version of Dependency, empty if missing
+}
+
+func (p PluginProblem) String() string {
+	switch p.Kind {
+	case PLUGIN_MISSING_DEPENDENCY:
+		return fmt.Sprintf("%s requires %s %s, which is not installed", p.Plugin, p.Dependency, p.Required)
+	case PLUGIN_VERSION_CONFLICT:
+		return fmt.Sprintf("%s requires %s %s, but %s is installed", p.Plugin, p.Dependency, p.Required, p.Installed)
+	}
+	return fmt.Sprintf("%s requires %s, which is disabled", p.Plugin, p.Dependency)
+}
+
+// PluginGraph is the dependency graph of the installed plugins. Plugins offered by
+// the update sites can be added with AddAvailable to resolve plugins to install.
+type PluginGraph struct {
+	plugins    map[string]Plugin
+	dependents map[string][]string
+	available  map[string]UpdateSitePlugin
+}
+
+// NewPluginGraph builds the graph of the given plugins,
+// which need their dependencies, i.e. GetPlugins with a depth of at least 1.
+func NewPluginGraph(plugins []Plugin) *PluginGraph {
+	g := &PluginGraph{plugins: make(map[string]Plugin), dependents: make(map[string][]string), available: make(map[string]UpdateSitePlugin)}
+	for _, p := range plugins {
+		g.plugins[p.ShortName] = p
+		for _, d := range p.Dependencies {
+			g.dependents[d.ShortName] = append(g.dependents[d.ShortName], p.ShortName)
+		}
+	}
+	for _, names := range g.dependents {
+		sort.Strings(names)
+	}
+	return g
+}
+
+// Graph returns the dependency graph of the plugins.
+func (p *Plugins) Graph() *PluginGraph {
+	return NewPluginGraph(p.Raw.Plugins)
+}
+
+// GetPluginGraph returns the dependency graph of the installed plugins.
+func (c *Client) GetPluginGraph() (*PluginGraph, error) {
+	plugins, err := c.GetPlugins(1)
+	if err != nil {
+		return nil, err
+	}
+	return plugins.Graph(), nil
+}
+
+// AddAvailable adds the plugins offered by the update sites, see GetAvailablePlugins.
+// RequiredFor uses them for the plugins that are not installed.
+func (g *PluginGraph) AddAvailable(plugins []UpdateSitePlugin) {
+	for _, p := range plugins {
+		if a, ok := g.available[p.Name]; !ok || CompareVersions(p.Version, a.Version) > 0 {
+			g.available[p.Name] = p
+		}
+	}
+}
+
+// dependenciesOf returns the version and dependencies of an installed plugin, or else of an available one.
+func (g *PluginGraph) dependenciesOf(name string) (string, []PluginDependency, bool) {
+	if p, ok := g.plugins[name]; ok {
+		return p.Version, p.Dependencies, true
+	}
+	a, ok := g.available[name]
+	if !ok {
+		return "", nil, false
+	}
+	deps := make([]PluginDependency, 0, len(a.Dependencies)+len(a.OptionalDependencies))
+	for _, d := range sortedMapKeys(a.Dependencies) {
+		deps = append(deps, PluginDependency{ShortName: d, Version: a.Dependencies[d]})
+	}
+	for _, d := range sortedMapKeys(a.OptionalDependencies) {
+		deps = append(deps, PluginDependency{ShortName: d, Version: a.OptionalDependencies[d], Optional: true})
+	}
+	return a.Version, deps, true
+}
+
+func (g *PluginGraph) Plugin(name string) (Plugin, bool) {
+	p, ok := g.plugins[name]
+	return p, ok
+}
+
+// Names returns the names of the installed plugins, sorted.
+func (g *PluginGraph) Names() []string {
+	names := make([]string, 0, len(g.plugins))
+	for name := range g.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dependents returns the installed plugins depending on the plugin, optionally or not.
+func (g *PluginGraph) Dependents(name string) []string {
+	return g.dependents[name]
+}
+
+// Problems returns the dependencies that keep enabled plugins from loading: missing
+// required dependencies, dependencies older than required and disabled required dependencies.
+func (g *PluginGraph) Problems() []PluginProblem {
+	problems := make([]PluginProblem, 0)
+	for _, name := range g.Names() {
+		p := g.plugins[name]
+		if !p.Enabled || p.Deleted {
+			continue
+		}
+		for _, d := range p.Dependencies {
+			dep, ok := g.plugins[d.ShortName]
+			problem := PluginProblem{Plugin: name, Dependency: d.ShortName, Required: d.Version, Installed: dep.Version}
+			switch {
+			case !ok || dep.Deleted:
+				if d.Optional {
+					continue
+				}
+				problem.Kind = PLUGIN_MISSING_DEPENDENCY
+			case d.Version != "" && CompareVersions(dep.Version, d.Version) < 0:
+				// an optional dependency that is installed has to be recent enough as well
+				problem.Kind = PLUGIN_VERSION_CONFLICT
+			case !dep.Enabled && !d.Optional:
+				problem.Kind = PLUGIN_DISABLED_DEPENDENCY
+			default:
+				continue
+			}
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
+
+// RequiredFor returns the plugins needed to install or run the plugin: the plugin and its
+// transitive dependencies, dependencies first, each with the highest version required and the
+// plugin itself with its installed or available version. Optional dependencies are followed
+// when optional is set. Plugins that are not installed are resolved with the plugins added by
+// AddAvailable, RequiredFor fails if a plugin is neither installed nor available.
+func (g *PluginGraph) RequiredFor(name string, optional bool) ([]PluginSpec, error) {
+	version, _, ok := g.dependenciesOf(name)
+	if !ok {
+		return nil, errors.New("Plugin " + name + " is neither installed nor available")
+	}
+	versions := map[string]string{name: version}
+	order := make([]string, 0)
+	visited := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		_, deps, ok := g.dependenciesOf(name)
+		if !ok {
+			return errors.New("Plugin " + name + " is neither installed nor available")
+		}
+		for _, d := range deps {
+			if d.Optional && !optional {
+				continue
+			}
+			if v, ok := versions[d.ShortName]; !ok || CompareVersions(d.Version, v) > 0 {
+				versions[d.ShortName] = d.Version
+			}
+			if err := visit(d.ShortName); err != nil {
+				return err
+			}
+		}
+		order = append(order, name)
+		return nil
+	}
+	if err := visit(name); err != nil {
+		return nil, err
+	}
+
+	specs := make([]PluginSpec, len(order))
+	for i, n := range order {
+		specs[i] = PluginSpec{Name: n, Version: versions[n]}
+	}
+	return specs, nil
+}
+
+// GetAvailablePlugins returns the plugins offered by the update sites, including the newer
+// versions of installed plugins.
+func (c *Client) GetAvailablePlugins() ([]UpdateSitePlugin, error) {
+	var resp struct {
+		Sites []struct {
+			Availables []UpdateSitePlugin `json:"availables"`
+			Updates    []UpdateSitePlugin `json:"updates"`
+		} `json:"sites"`
+	}
+	fields := Fields("name", "title", "version", "requiredCore", "url", "dependencies", "optionalDependencies")
+	tree := []TreeField{Tree("sites", Tree("availables", fields), Tree("updates", fields))}
+	r, err := c.Requester.GetJSON("/updateCenter", &resp, treeQueryString(tree))
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(r.StatusCode))
+	}
+	plugins := make([]UpdateSitePlugin, 0)
+	for _, s := range resp.Sites {
+		plugins = append(plugins, s.Availables...)
+		plugins = append(plugins, s.Updates...)
+	}
+	return plugins, nil
+}
+
+// PluginsRequiredFor returns the plugins needed to install the plugin, see PluginGraph.RequiredFor.
+// Example: specs, _ := jenkins.PluginsRequiredFor("git", false); jenkins.InstallPlugins(ctx, specs)
+func (c *Client) PluginsRequiredFor(name string, optional bool) ([]PluginSpec, error) {
+	graph, err := c.GetPluginGraph()
+	if err != nil {
+		return nil, err
+	}
+	available, err := c.GetAvailablePlugins()
+	if err != nil {
+		return nil, err
+	}
+	graph.AddAvailable(available)
+	return graph.RequiredFor(name, optional)
+}
+
+// DOT returns the graph in the Graphviz dot language. Optional dependencies are dashed,
+// disabled plugins gray and missing plugins red.
+func (g *PluginGraph) DOT() string {
+	var b bytes.Buffer
+	b.WriteString("digraph plugins {\n")
+	missing := make(map[string]bool)
+	for _, name := range g.Names() {
+		p := g.plugins[name]
+		attrs := fmt.Sprintf("label=%q", name+" "+p.Version)
+		if !p.Enabled {
+			attrs += ", color=gray, fontcolor=gray"
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", name, attrs)
+		for _, d := range p.Dependencies {
+			if _, ok := g.plugins[d.ShortName]; !ok {
+				missing[d.ShortName] = true
+			}
+		}
+	}
+	for _, name := range sortedKeys(missing) {
+		fmt.Fprintf(&b, "  %q [color=red, fontcolor=red];\n", name)
+	}
+	for _, name := range g.Names() {
+		for _, d := range g.plugins[name].Dependencies {
+			attrs := fmt.Sprintf("label=%q", d.Version)
+			if d.Optional {
+				attrs += ", style=dashed"
+			}
+			fmt.Fprintf(&b, "  %q -> %q [%s];\n", name, d.ShortName, attrs)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func sortedMapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type pluginGraphJSON struct {
+	Plugins []pluginGraphNode `json:"plugins"`
+	Edges   []pluginGraphEdge `json:"dependencies"`
+}
+
+type pluginGraphNode struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+type pluginGraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Version  string `json:"version"`
+	Optional bool   `json:"optional"`
+}
+
+// MarshalJSON exports the graph as {"plugins": [...], "dependencies": [{"from", "to", "version", "optional"}]}.
+func (g *PluginGraph) MarshalJSON() ([]byte, error) {
+	out := pluginGraphJSON{Plugins: make([]pluginGraphNode, 0), Edges: make([]pluginGraphEdge, 0)}
+	missing := make(map[string]bool)
+	for _, name := range g.Names() {
+		p := g.plugins[name]
+		out.Plugins = append(out.Plugins, pluginGraphNode{Name: name, Version: p.Version, Enabled: p.Enabled})
+		for _, d := range p.Dependencies {
+			out.Edges = append(out.Edges, pluginGraphEdge{From: name, To: d.ShortName, Version: d.Version, Optional: d.Optional})
+			if _, ok := g.plugins[d.ShortName]; !ok {
+				missing[d.ShortName] = true
+			}
+		}
+	}
+	for _, name := range sortedKeys(missing) {
+		out.Plugins = append(out.Plugins, pluginGraphNode{Name: name, Missing: true})
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,289 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPluginInstallPollInterval is the time between two checks of the update center jobs
+// when Client.PollInterval is not set.
+const DefaultPluginInstallPollInterval = 2 * time.Second
+
+// PluginSpec names a plugin and optionally its minimum version, written name@version.
+type PluginSpec struct {
+	Name    string
+	Version string
+}
+
+// ParsePluginSpec parses name or name@version.
+func ParsePluginSpec(spec string) (PluginSpec, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "@", 2)
+	if parts[0] == "" {
+		return PluginSpec{}, errors.New("plugin spec without name: " + spec)
+	}
+	p := PluginSpec{Name: parts[0]}
+	if len(parts) == 2 {
+		p.Version = parts[1]
+	}
+	return p, nil
+}
+
+func (p PluginSpec) String() string {
+	if p.Version == "" {
+		return p.Name
+	}
+	return p.Name + "@" + p.Version
+}
+
+// Status types of the update center jobs.
+const (
+	PLUGIN_INSTALL_PENDING        = "Pending"
+	PLUGIN_INSTALL_INSTALLING     = "Installing"
+	PLUGIN_INSTALL_SUCCESS        = "Success"
+	PLUGIN_INSTALL_RESTART_NEEDED = "SuccessButRequiresRestart"
+	PLUGIN_INSTALL_FAILURE        = "Failure"
+	PLUGIN_INSTALL_SKIPPED        = "Skipped"
+)
+
+const updateCenterInstallationJob = "InstallationJob"
+
+type updateCenterJob struct {
+	ID     int64  `json:"id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Status struct {
+		Type    string `json:"type"`
+		Success bool   `json:"success"`
+	} `json:"status"`
+	ErrorMessage string `json:"errorMessage"`
+	Plugin       struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"plugin"`
+}
+
+type updateCenterJobs struct {
+	Jobs                         []updateCenterJob `json:"jobs"`
+	RestartRequiredForCompletion bool              `json:"restartRequiredForCompletion"`
+}
+
+func (c *Client) getUpdateCenterJobs() (*updateCenterJobs, error) {
+	jobs := new(updateCenterJobs)
+	tree := []TreeField{
+		Fields("restartRequiredForCompletion"),
+		Tree("jobs", Fields("id", "type", "name", "errorMessage"), Tree("status", Fields("type", "success")), Tree("plugin", Fields("name", "version"))),
+	}
+	resp, err := c.Requester.GetJSON("/updateCenter", jobs, treeQueryString(tree))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	return jobs, nil
+}
+
+// PluginInstallResult is the outcome of the installation of one plugin,
+// requested or pulled in as a dependency.
+type PluginInstallResult struct {
+	Name    string
+	Version string
+	Status  string // one of the PLUGIN_INSTALL_ status types
+	Error   string
+}
+
+func (r PluginInstallResult) Failed() bool {
+	return r.Status == PLUGIN_INSTALL_FAILURE
+}
+
+// PluginInstallReport lists the plugins installed by InstallPlugins. Requested plugins
+// that were already installed in the requested version have no result.
+type PluginInstallReport struct {
+	Results         []PluginInstallResult
+	RestartRequired bool
+}
+
+// Failed returns the plugins that could not be installed.
+func (r *PluginInstallReport) Failed() []PluginInstallResult {
+	failed := make([]PluginInstallResult, 0)
+	for _, res := range r.Results {
+		if res.Failed() {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// InstallPlugins installs plugins and their dependencies from the update center and waits until
+// the update center finished, or ctx is done. A plugin is installed or updated when it is missing
+// or older than the requested version; the update center installs its own latest version,
+// so a spec cannot pin an older version. Without version only missing plugins are installed.
+// Example: jenkins.InstallPlugins(ctx, []gojenkins.PluginSpec{{Name: "git", Version: "5.2.0"}})
+func (c *Client) InstallPlugins(ctx context.Context, plugins []PluginSpec) (*PluginInstallReport, error) {
+	before, err := c.getUpdateCenterJobs()
+	if err != nil {
+		return nil, err
+	}
+	var lastID int64 = -1
+	for _, j := range before.Jobs {
+		if j.ID > lastID {
+			lastID = j.ID
+		}
+	}
+
+	var body bytes.Buffer
+	body.WriteString("<jenkins>")
+	for _, p := range plugins {
+		version := p.Version
+		if version == "" {
+			// any installed version satisfies 0, so only a missing plugin is installed
+			version = "0"
+		}
+		body.WriteString(`<install plugin="`)
+		xml.EscapeText(&body, []byte(p.Name+"@"+version))
+		body.WriteString(`"/>`)
+	}
+	body.WriteString("</jenkins>")
+	resp, err := c.Requester.PostXML("/pluginManager/installNecessaryPlugins", body.String(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, errors.New("Could not install plugins: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	report := new(PluginInstallReport)
+	err = pollUntil(ctx, c.pollInterval(DefaultPluginInstallPollInterval), func() (bool, error) {
+		jobs, err := c.getUpdateCenterJobs()
+		if err != nil {
+			return false, err
+		}
+		report.Results = report.Results[:0]
+		report.RestartRequired = jobs.RestartRequiredForCompletion
+		done := true
+		for _, j := range jobs.Jobs {
+			if j.ID <= lastID || j.Type != updateCenterInstallationJob {
+				continue
+			}
+			name := j.Plugin.Name
+			if name == "" {
+				name = j.Name
+			}
+			report.Results = append(report.Results, PluginInstallResult{Name: name, Version: j.Plugin.Version, Status: j.Status.Type, Error: j.ErrorMessage})
+			if j.Status.Type == PLUGIN_INSTALL_PENDING || j.Status.Type == PLUGIN_INSTALL_INSTALLING {
+				done = false
+			}
+			if j.Status.Type == PLUGIN_INSTALL_RESTART_NEEDED {
+				report.RestartRequired = true
+			}
+		}
+		return done, nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if failed := report.Failed(); len(failed) > 0 {
+		names := make([]string, len(failed))
+		for i, f := range failed {
+			names[i] = f.Name
+		}
+		return report, fmt.Errorf("Could not install plugins: %s", strings.Join(names, ", "))
+	}
+	return report, nil
+}
+
+func (c *Client) pluginAction(name string, action string) error {
+	resp, err := c.Requester.Post("/pluginManager/plugin/"+url.PathEscape(name)+"/"+action, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Could not %s plugin %s: %d", action, name, resp.StatusCode)
+	}
+	return nil
+}
+
+// EnablePlugin enables an installed plugin, it is loaded after a restart.
+func (c *Client) EnablePlugin(name string) error {
+	return c.pluginAction(name, "makeEnabled")
+}
+
+// DisablePlugin disables a plugin, it is unloaded after a restart.
+func (c *Client) DisablePlugin(name string) error {
+	return c.pluginAction(name, "makeDisabled")
+}
+
+// UninstallPlugin removes a plugin, it is unloaded after a restart.
+func (c *Client) UninstallPlugin(name string) error {
+	return c.pluginAction(name, "doUninstall")
+}
+
+// Changes of a plugin that take effect after a restart.
+const (
+	PLUGIN_CHANGE_ENABLE    = "enable"
+	PLUGIN_CHANGE_DISABLE   = "disable"
+	PLUGIN_CHANGE_UNINSTALL = "uninstall"
+)
+
+type PendingPluginChange struct {
+	Name   string
+	Change string
+}
+
+// PluginRestartReport tells whether Jenkins needs a restart to complete plugin changes.
+type PluginRestartReport struct {
+	Required bool
+	Changes  []PendingPluginChange
+}
+
+// GetPluginRestartReport lists the plugin changes waiting for a restart: plugins enabled,
+// disabled or uninstalled since the start. Required is also set when the update center
+// installed or updated plugins that need a restart.
+func (c *Client) GetPluginRestartReport() (*PluginRestartReport, error) {
+	jobs, err := c.getUpdateCenterJobs()
+	if err != nil {
+		return nil, err
+	}
+	plugins, err := c.GetPlugins(1)
+	if err != nil {
+		return nil, err
+	}
+	report := &PluginRestartReport{Required: jobs.RestartRequiredForCompletion, Changes: make([]PendingPluginChange, 0)}
+	for _, p := range plugins.Raw.Plugins {
+		change := ""
+		switch {
+		case p.Deleted:
+			change = PLUGIN_CHANGE_UNINSTALL
+		case p.Enabled && !p.Active:
+			change = PLUGIN_CHANGE_ENABLE
+		case !p.Enabled && p.Active:
+			change = PLUGIN_CHANGE_DISABLE
+		}
+		if change != "" {
+			report.Changes = append(report.Changes, PendingPluginChange{Name: p.ShortName, Change: change})
+			report.Required = true
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PluginSet maps plugin short names to versions, as listed in a plugins.txt file.
+type PluginSet map[string]string
+
+// Set returns the installed plugins, without the ones uninstalled pending a restart.
+func (p *Plugins) Set() PluginSet {
+	set := make(PluginSet, len(p.Raw.Plugins))
+	for _, plugin := range p.Raw.Plugins {
+		if !plugin.Deleted {
+			set[plugin.ShortName] = plugin.Version
+		}
+	}
+	return set
+}
+
+// PluginsTxt returns the installed plugins in the plugins.txt format of jenkins-plugin-cli.
+func (p *Plugins) PluginsTxt() string {
+	return p.Set().PluginsTxt()
+}
+
+// GetPluginSet returns the installed plugins.
+func (c *Client) GetPluginSet() (PluginSet, error) {
+	plugins, err := c.GetPlugins(1)
+	if err != nil {
+		return nil, err
+	}
+	return plugins.Set(), nil
+}
+
+// Names returns the plugin names, sorted.
+func (s PluginSet) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PluginsTxt returns one name:version line per plugin, sorted by name.
+// Plugins without version are written as name:latest.
+func (s PluginSet) PluginsTxt() string {
+	var b bytes.Buffer
+	for _, name := range s.Names() {
+		version := s[name]
+		if version == "" {
+			version = "latest"
+		}
+		b.WriteString(name + ":" + version + "\n")
+	}
+	return b.String()
+}
+
+// ParsePluginsTxt reads a plugins.txt file: one name:version per line, # starts a comment.
+// A line without version means the latest version, stored as "latest". Download URLs
+// after the version (name:version:url) are ignored.
+func ParsePluginsTxt(r io.Reader) (PluginSet, error) {
+	set := make(PluginSet)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		parts := strings.SplitN(text, ":", 3)
+		name := strings.TrimSpace(parts[0])
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("plugins.txt line %d: invalid plugin %q", line, text)
+		}
+		version := "latest"
+		if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+			version = strings.TrimSpace(parts[1])
+		}
+		if _, ok := set[name]; ok {
+			return nil, fmt.Errorf("plugins.txt line %d: plugin %s is listed twice", line, name)
+		}
+		set[name] = version
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// PluginVersionChange is a plugin installed in different versions.
+type PluginVersionChange struct {
+	Name string
+	From string
+	To   string
+}
+
+// PluginSetDiff lists the changes turning one plugin set into another, sorted by name.
+// Changed holds version changes that cannot be ordered, e.g. from latest to 1.2.
+type PluginSetDiff struct {
+	Added      []PluginSpec
+	Removed    []PluginSpec
+	Upgraded   []PluginVersionChange
+	Downgraded []PluginVersionChange
+	Changed    []PluginVersionChange
+}
+
+func (d *PluginSetDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Upgraded) == 0 && len(d.Downgraded) == 0 && len(d.Changed) == 0
+}
+
+// isVersionNumber tells whether a plugins.txt version is a version number and not a
+// keyword such as latest, experimental or an incrementals reference.
+func isVersionNumber(v string) bool {
+	return v != "" && v[0] >= '0' && v[0] <= '9' && !strings.Contains(v, "incrementals")
+}
+
+// DiffPluginSets compares two plugin sets, e.g. staging and production:
+// diff := gojenkins.DiffPluginSets(staging, production) lists what production has in addition to staging.
+func DiffPluginSets(from, to PluginSet) *PluginSetDiff {
+	diff := &PluginSetDiff{
+		Added:      make([]PluginSpec, 0),
+		Removed:    make([]PluginSpec, 0),
+		Upgraded:   make([]PluginVersionChange, 0),
+		Downgraded: make([]PluginVersionChange, 0),
+		Changed:    make([]PluginVersionChange, 0),
+	}
+	for _, name := range from.Names() {
+		if _, ok := to[name]; !ok {
+			diff.Removed = append(diff.Removed, PluginSpec{Name: name, Version: from[name]})
+		}
+	}
+	for _, name := range to.Names() {
+		version := to[name]
+		old, ok := from[name]
+		if !ok {
+			diff.Added = append(diff.Added, PluginSpec{Name: name, Version: version})
+			continue
+		}
+		if old == version {
+			continue
+		}
+		change := PluginVersionChange{Name: name, From: old, To: version}
+		// equal versions written differently, e.g. 1.0 and 1.0.0, are no change
+		switch {
+		case !isVersionNumber(old) || !isVersionNumber(version):
+			diff.Changed = append(diff.Changed, change)
+		case CompareVersions(old, version) < 0:
+			diff.Upgraded = append(diff.Upgraded, change)
+		case CompareVersions(old, version) > 0:
+			diff.Downgraded = append(diff.Downgraded, change)
+		}
+	}
+	return diff
+}
+
+// DiffPlugins compares the plugins installed on two instances.
+func (c *Client) DiffPlugins(other *Client) (*PluginSetDiff, error) {
+	from, err := c.GetPluginSet()
+	if err != nil {
+		return nil, err
+	}
+	to, err := other.GetPluginSet()
+	if err != nil {
+		return nil, err
+	}
+	return DiffPluginSets(from, to), nil
+}
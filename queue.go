@@ -15,7 +15,11 @@
 package gojenkins
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 type Queue struct {
@@ -51,6 +55,14 @@ type taskResponse struct {
 	} `json:"task"`
 	URL string `json:"url"`
 	Why string `json:"why"`
+	// Class tells the state of the item: waiting, blocked, buildable or left the queue.
+	Class string `json:"_class"`
+	// Set once the item left the queue, only returned by GetQueueItem.
+	Cancelled  bool `json:"cancelled"`
+	Executable *struct {
+		Number int64  `json:"number"`
+		URL    string `json:"url"`
+	} `json:"executable"`
 }
 
 type generalAction struct {
@@ -60,16 +72,16 @@ type generalAction struct {
 
 func (q *Queue) Tasks() []*Task {
 	tasks := make([]*Task, len(q.Raw.Items))
-	for i, t := range q.Raw.Items {
-		tasks[i] = &Task{Jenkins: q.Client, Queue: q, Raw: &t}
+	for i := range q.Raw.Items {
+		tasks[i] = &Task{Jenkins: q.Client, Queue: q, Raw: &q.Raw.Items[i]}
 	}
 	return tasks
 }
 
 func (q *Queue) GetTaskById(id int64) *Task {
-	for _, t := range q.Raw.Items {
-		if t.ID == id {
-			return &Task{Jenkins: q.Client, Queue: q, Raw: &t}
+	for i := range q.Raw.Items {
+		if q.Raw.Items[i].ID == id {
+			return &Task{Jenkins: q.Client, Queue: q, Raw: &q.Raw.Items[i]}
 		}
 	}
 	return nil
@@ -77,9 +89,9 @@ func (q *Queue) GetTaskById(id int64) *Task {
 
 func (q *Queue) GetTasksForJob(name string) []*Task {
 	tasks := make([]*Task, 0)
-	for _, t := range q.Raw.Items {
-		if t.Task.Name == name {
-			tasks = append(tasks, &Task{Jenkins: q.Client, Queue: q, Raw: &t})
+	for i := range q.Raw.Items {
+		if q.Raw.Items[i].Task.Name == name {
+			tasks = append(tasks, &Task{Jenkins: q.Client, Queue: q, Raw: &q.Raw.Items[i]})
 		}
 	}
 	return tasks
@@ -87,6 +99,9 @@ func (q *Queue) GetTasksForJob(name string) []*Task {
 
 func (q *Queue) CancelTask(id int64) (bool, error) {
 	task := q.GetTaskById(id)
+	if task == nil {
+		return false, fmt.Errorf("No task with id %d in the queue", id)
+	}
 	return task.Cancel()
 }
 
@@ -109,6 +124,101 @@ func (t *Task) GetWhy() string {
 	return t.Raw.Why
 }
 
+// Reasons why an item waits in the queue, see Task.GetWhyReason.
+const (
+	WHY_UNKNOWN               = "unknown"
+	WHY_WAITING_FOR_EXECUTOR  = "waiting for executor"
+	WHY_NO_NODE_FOR_LABEL     = "no node for label"
+	WHY_NODES_OFFLINE         = "nodes offline"
+	WHY_BLOCKED_BY_UPSTREAM   = "blocked by upstream"
+	WHY_BLOCKED_BY_DOWNSTREAM = "blocked by downstream"
+	WHY_ALREADY_RUNNING       = "already running"
+	WHY_QUIET_PERIOD          = "quiet period"
+	WHY_THROTTLED             = "throttled"
+)
+
+// QueueWhy is the classified reason of a queue item, Label or Project are set
+// when the message names them.
+type QueueWhy struct {
+	Reason  string
+	Label   string
+	Project string
+	Message string
+}
+
+// Jenkins quotes names with ‘’ since 2.x, older versions use plain text or single quotes.
+// Some messages end with a period.
+const whyName = `[‘'"]?(.+?)[’'"]?`
+
+var whyPatterns = []struct {
+	reason string
+	re     *regexp.Regexp
+	label  bool
+}{
+	{WHY_QUIET_PERIOD, regexp.MustCompile(`^In the quiet period`), false},
+	{WHY_WAITING_FOR_EXECUTOR, regexp.MustCompile(`^Waiting for next available executor on ` + whyName + `\.?$`), true},
+	{WHY_WAITING_FOR_EXECUTOR, regexp.MustCompile(`^Waiting for next available executor`), false},
+	{WHY_NO_NODE_FOR_LABEL, regexp.MustCompile(`^There are no nodes with the label ` + whyName + `\.?$`), true},
+	{WHY_NODES_OFFLINE, regexp.MustCompile(`^All nodes of label ` + whyName + ` are offline\.?$`), true},
+	{WHY_NODES_OFFLINE, regexp.MustCompile(`^` + whyName + ` is offline\.?$`), true},
+	{WHY_BLOCKED_BY_UPSTREAM, regexp.MustCompile(`^Upstream project ` + whyName + ` is already in progress\.?$`), false},
+	{WHY_BLOCKED_BY_DOWNSTREAM, regexp.MustCompile(`^Downstream project ` + whyName + ` is already in progress\.?$`), false},
+	{WHY_ALREADY_RUNNING, regexp.MustCompile(`^Build #\d+ is already in progress`), false},
+	// Throttle Concurrent Builds plugin
+	{WHY_THROTTLED, regexp.MustCompile(`^(Already running \d+ builds?|Maximum total concurrent builds reached|Blocked by throttle)`), false},
+}
+
+// ClassifyWhy classifies the why message of a queue item.
+// Messages of unknown plugins and localized messages are WHY_UNKNOWN.
+func ClassifyWhy(why string) QueueWhy {
+	msg := strings.TrimSpace(why)
+	for _, p := range whyPatterns {
+		m := p.re.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		w := QueueWhy{Reason: p.reason, Message: why}
+		if len(m) > 1 {
+			if p.label {
+				w.Label = m[1]
+			} else {
+				w.Project = m[1]
+			}
+		}
+		return w
+	}
+	return QueueWhy{Reason: WHY_UNKNOWN, Message: why}
+}
+
+func (t *Task) GetWhyReason() QueueWhy {
+	return ClassifyWhy(t.Raw.Why)
+}
+
+// IsLeft tells whether the item left the queue, either cancelled or started.
+func (t *Task) IsLeft() bool {
+	return strings.HasSuffix(t.Raw.Class, "$LeftItem") || t.Raw.Cancelled || t.Raw.Executable != nil
+}
+
+// ErrNoBuild is returned by Task.GetBuild while the item waits in the queue or when it was cancelled.
+var ErrNoBuild = errors.New("No build started for the queue item")
+
+// GetBuild returns the build started for the item, ErrNoBuild when there is none.
+func (t *Task) GetBuild() (*Build, error) {
+	if t.Raw.Executable == nil {
+		return nil, ErrNoBuild
+	}
+	name, number, ok := parseBuildEndpoint(t.Jenkins.endpointOf(t.Raw.Executable.URL))
+	if !ok {
+		return nil, errors.New("Unexpected build URL " + t.Raw.Executable.URL)
+	}
+	path := strings.Split(name, "/")
+	job, err := t.Jenkins.GetJob(path[len(path)-1], path[:len(path)-1]...)
+	if err != nil {
+		return nil, err
+	}
+	return job.GetBuild(number)
+}
+
 func (t *Task) GetParameters() []parameter {
 	for _, a := range t.Raw.Actions {
 		if a.Parameters != nil {
@@ -128,7 +238,12 @@ func (t *Task) GetCauses() []map[string]interface{} {
 }
 
 func (q *Queue) Poll() (int, error) {
-	response, err := q.Client.Requester.GetJSON(q.Base, q.Raw, nil)
+	return q.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (q *Queue) PollTree(tree ...TreeField) (int, error) {
+	response, err := q.Client.Requester.GetJSON(q.Base, q.Raw, treeQueryString(tree))
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,117 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultQueueWatchInterval is the time between two polls of WatchQueue when neither
+// QueueWatchOptions.Interval nor Client.PollInterval is set.
+const DefaultQueueWatchInterval = 5 * time.Second
+
+// Types of the events sent by WatchQueue.
+const (
+	QUEUE_ENQUEUED = "enqueued"
+	QUEUE_LEFT     = "left"
+	QUEUE_STUCK    = "stuck"
+	QUEUE_ERROR    = "error"
+)
+
+// QueueEvent is sent by WatchQueue. Task is the last known state of the item,
+// for QUEUE_LEFT it is the item looked up with GetQueueItem when Jenkins still knows it,
+// so that Task.GetBuild returns the started build. Err is only set for QUEUE_ERROR.
+type QueueEvent struct {
+	Type string
+	Task *Task
+	Time time.Time
+	Err  error
+}
+
+// QueueWatchOptions configure WatchQueue.
+type QueueWatchOptions struct {
+	// Interval between two polls of the queue, defaults to Client.PollInterval or DefaultQueueWatchInterval.
+	Interval time.Duration
+	// StuckAfter reports items waiting longer than this as stuck. Items Jenkins
+	// marks as stuck are always reported, zero only relies on Jenkins.
+	StuckAfter time.Duration
+}
+
+// WatchQueue polls the queue until ctx is done and sends an event when an item enters
+// the queue, leaves it or gets stuck. Items already waiting are sent as QUEUE_ENQUEUED
+// on the first poll. Failed polls are sent as QUEUE_ERROR and the watch goes on.
+// The channel is closed when ctx is done.
+// Example: for e := range jenkins.WatchQueue(ctx, gojenkins.QueueWatchOptions{StuckAfter: time.Hour}) { ... }
+func (c *Client) WatchQueue(ctx context.Context, options QueueWatchOptions) <-chan QueueEvent {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = c.pollInterval(DefaultQueueWatchInterval)
+	}
+	events := make(chan QueueEvent)
+	send := func(e QueueEvent) bool {
+		select {
+		case events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(events)
+		known := make(map[int64]*Task)
+		stuck := make(map[int64]bool)
+		pollUntil(ctx, interval, func() (bool, error) {
+			q, err := c.GetQueue()
+			now := time.Now()
+			if err != nil {
+				return !send(QueueEvent{Type: QUEUE_ERROR, Time: now, Err: err}), nil
+			}
+
+			current := make(map[int64]*Task)
+			for _, t := range q.Tasks() {
+				id := t.Raw.ID
+				current[id] = t
+				if _, ok := known[id]; !ok && !send(QueueEvent{Type: QUEUE_ENQUEUED, Task: t, Time: now}) {
+					return true, nil
+				}
+				waiting := now.Sub(time.Unix(0, t.Raw.InQueueSince*int64(time.Millisecond)))
+				if !stuck[id] && (t.Raw.Stuck || (options.StuckAfter > 0 && waiting > options.StuckAfter)) {
+					stuck[id] = true
+					if !send(QueueEvent{Type: QUEUE_STUCK, Task: t, Time: now}) {
+						return true, nil
+					}
+				}
+			}
+
+			for id, t := range known {
+				if _, ok := current[id]; ok {
+					continue
+				}
+				if item, err := c.GetQueueItem(id); err == nil {
+					t = item
+				}
+				delete(stuck, id)
+				if !send(QueueEvent{Type: QUEUE_LEFT, Task: t, Time: now}) {
+					return true, nil
+				}
+			}
+			known = current
+			return false, nil
+		})
+	}()
+	return events
+}
@@ -16,6 +16,7 @@ package gojenkins
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -37,6 +38,8 @@ type APIRequest struct {
 	Payload  io.Reader
 	Headers  http.Header
 	Suffix   string
+	// Context cancels the request when done, optional.
+	Context context.Context
 }
 
 func (ar *APIRequest) SetHeader(key string, value string) *APIRequest {
@@ -47,7 +50,7 @@ func (ar *APIRequest) SetHeader(key string, value string) *APIRequest {
 func NewAPIRequest(method string, endpoint string, payload io.Reader) *APIRequest {
 	var headers = http.Header{}
 	var suffix string
-	ar := &APIRequest{method, endpoint, payload, headers, suffix}
+	ar := &APIRequest{Method: method, Endpoint: endpoint, Payload: payload, Headers: headers, Suffix: suffix}
 	return ar
 }
 
@@ -223,6 +226,10 @@ func (r *Requester) Do(ar *APIRequest, responseStruct interface{}, options ...in
 		req.Header.Add(k, ar.Headers.Get(k))
 	}
 
+	if ar.Context != nil {
+		req = req.WithContext(ar.Context)
+	}
+
 	if response, err := r.Client.Do(req); err != nil {
 		return nil, err
 	} else {
@@ -0,0 +1,216 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+// BuildSelector decides whether a build of the history is selected, e.g. for deletion.
+type BuildSelector func(b BuildSummary) bool
+
+// OlderThan selects builds started more than d ago.
+func OlderThan(d time.Duration) BuildSelector {
+	limit := time.Now().Add(-d)
+	return func(b BuildSummary) bool {
+		return b.GetTimestamp().Before(limit)
+	}
+}
+
+// ResultIn selects builds with one of the given results.
+func ResultIn(results ...string) BuildSelector {
+	return func(b BuildSummary) bool {
+		for _, r := range results {
+			if b.Result == r {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NotKept selects builds that are not marked to be kept forever.
+func NotKept() BuildSelector {
+	return func(b BuildSummary) bool {
+		return !b.KeepLog
+	}
+}
+
+// NumberBetween selects builds numbered from from to to, both included.
+func NumberBetween(from, to int64) BuildSelector {
+	return func(b BuildSummary) bool {
+		return b.Number >= from && b.Number <= to
+	}
+}
+
+// AllOf selects builds matching every selector.
+func AllOf(selectors ...BuildSelector) BuildSelector {
+	return func(b BuildSummary) bool {
+		for _, s := range selectors {
+			if !s(b) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// BuildDeletionReport lists the builds removed by Job.DeleteBuilds,
+// or the builds that would be removed on a dry run.
+type BuildDeletionReport struct {
+	DryRun bool
+	Builds []BuildSummary
+}
+
+// DeleteBuilds deletes every finished build of the job matching the selector.
+// With dryRun nothing is deleted and the report lists the builds that would be.
+// Builds that could not be deleted are left out of the report and returned in a *BulkError.
+// Example: job.DeleteBuilds(gojenkins.AllOf(gojenkins.OlderThan(30*24*time.Hour), gojenkins.NotKept()), true)
+func (j *Job) DeleteBuilds(selector BuildSelector, dryRun bool) (*BuildDeletionReport, error) {
+	selected := make([]BuildSummary, 0)
+	it := j.IterateBuilds(BuildQuery{})
+	for it.Next() {
+		b := it.Build()
+		if !b.Building && selector(b) {
+			selected = append(selected, b)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &BuildDeletionReport{DryRun: dryRun, Builds: selected}
+	if dryRun {
+		return report, nil
+	}
+
+	names := make([]string, len(selected))
+	for i, b := range selected {
+		names[i] = "#" + strconv.FormatInt(b.Number, 10)
+	}
+	deleted := make([]bool, len(selected))
+	err := j.Client.fetchAll(names, func(i int) error {
+		build := Build{Client: j.Client, Job: j, Raw: new(BuildResponse), Depth: 1, Base: j.Base + "/" + strconv.FormatInt(selected[i].Number, 10)}
+		ok, err := build.Delete()
+		deleted[i] = ok
+		return err
+	})
+	report.Builds = make([]BuildSummary, 0, len(selected))
+	for i, b := range selected {
+		if deleted[i] {
+			report.Builds = append(report.Builds, b)
+		}
+	}
+	return report, err
+}
+
+// BuildDiscarder holds the "Discard old builds" settings of a job (hudson.tasks.LogRotator).
+// A value of -1 means no limit.
+type BuildDiscarder struct {
+	DaysToKeep         int `xml:"daysToKeep"`
+	NumToKeep          int `xml:"numToKeep"`
+	ArtifactDaysToKeep int `xml:"artifactDaysToKeep"`
+	ArtifactNumToKeep  int `xml:"artifactNumToKeep"`
+}
+
+type buildDiscarderProperty struct {
+	XMLName  xml.Name `xml:"jenkins.model.BuildDiscarderProperty"`
+	Strategy struct {
+		Class string `xml:"class,attr"`
+		BuildDiscarder
+	} `xml:"strategy"`
+}
+
+// GetBuildDiscarder returns the build discarder of the job, nil if old builds are never discarded.
+func (j *Job) GetBuildDiscarder() (*BuildDiscarder, error) {
+	config, err := j.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	var project struct {
+		Property *buildDiscarderProperty `xml:"properties>jenkins.model.BuildDiscarderProperty"`
+		// Jobs created before Jenkins 1.637 store the log rotator directly in the project.
+		LogRotator *BuildDiscarder `xml:"logRotator"`
+	}
+	if err := unmarshalXML(config, &project); err != nil {
+		return nil, err
+	}
+	if project.Property != nil {
+		return &project.Property.Strategy.BuildDiscarder, nil
+	}
+	return project.LogRotator, nil
+}
+
+// SetBuildDiscarder replaces the build discarder of the job, a nil discarder keeps all builds.
+func (j *Job) SetBuildDiscarder(discarder *BuildDiscarder) error {
+	data, err := j.GetConfig()
+	if err != nil {
+		return err
+	}
+	config := new(xmlConfig)
+	if err := unmarshalXML(data, config); err != nil {
+		return err
+	}
+
+	elements := make([]xmlElement, 0, len(config.Elements)+1)
+	properties := -1
+	for _, e := range config.Elements {
+		switch e.XMLName.Local {
+		case "logRotator":
+			// replaced by the property since Jenkins 1.637
+			continue
+		case "properties":
+			properties = len(elements)
+		}
+		elements = append(elements, e)
+	}
+	if properties < 0 {
+		elements = append(elements, xmlElement{XMLName: xml.Name{Local: "properties"}})
+		properties = len(elements) - 1
+	}
+
+	var current xmlConfig
+	if err := xml.Unmarshal([]byte("<properties>"+elements[properties].Inner+"</properties>"), &current); err != nil {
+		return err
+	}
+	var inner bytes.Buffer
+	for _, p := range current.Elements {
+		if p.XMLName.Local == "jenkins.model.BuildDiscarderProperty" {
+			continue
+		}
+		if err := xml.NewEncoder(&inner).Encode(p); err != nil {
+			return err
+		}
+	}
+	if discarder != nil {
+		property := buildDiscarderProperty{}
+		property.Strategy.Class = "hudson.tasks.LogRotator"
+		property.Strategy.BuildDiscarder = *discarder
+		if err := xml.NewEncoder(&inner).Encode(property); err != nil {
+			return err
+		}
+	}
+	elements[properties].Inner = inner.String()
+	config.Elements = elements
+
+	out, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return j.UpdateConfig(string(out))
+}
@@ -0,0 +1,159 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ScriptError is the exception thrown by a Groovy script, parsed from the stack trace
+// the script console prints instead of the result.
+type ScriptError struct {
+	Class      string // e.g. groovy.lang.MissingPropertyException
+	Message    string
+	StackTrace []string
+}
+
+func (e *ScriptError) Error() string {
+	if e.Message == "" {
+		return e.Class
+	}
+	return e.Class + ": " + e.Message
+}
+
+var exceptionLineRe = regexp.MustCompile(`^([a-zA-Z_$][\w$]*(?:\.[\w$]+)+)(?::\s?(.*))?$`)
+
+// parseScriptOutput splits the output of the script console into what the script printed
+// and the exception it threw, if any.
+func parseScriptOutput(output string) (string, *ScriptError) {
+	lines := strings.Split(output, "\n")
+	firstFrame := -1
+	for i, l := range lines {
+		if strings.HasPrefix(l, "\tat ") {
+			firstFrame = i
+			break
+		}
+	}
+	if firstFrame < 1 {
+		return output, nil
+	}
+	// The message of an exception can span several lines, e.g. for compilation errors.
+	for start := firstFrame - 1; start >= 0; start-- {
+		m := exceptionLineRe.FindStringSubmatch(strings.TrimRight(lines[start], "\r"))
+		if m == nil {
+			continue
+		}
+		message := append([]string{m[2]}, lines[start+1:firstFrame]...)
+		e := &ScriptError{Class: m[1], Message: strings.TrimSpace(strings.Join(message, "\n"))}
+		for _, l := range lines[firstFrame:] {
+			if strings.HasPrefix(l, "\t") || strings.HasPrefix(l, "Caused by: ") {
+				e.StackTrace = append(e.StackTrace, strings.TrimSpace(l))
+			}
+		}
+		return strings.Join(lines[:start], "\n"), e
+	}
+	return output, nil
+}
+
+func (c *Client) runScript(ctx context.Context, endpoint string, script string) (string, error) {
+	form := url.Values{"script": {script}}
+	ar := NewAPIRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err := c.Requester.SetCrumb(ar); err != nil {
+		return "", err
+	}
+	ar.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+	ar.Context = ctx
+
+	var output string
+	resp, err := c.Requester.Do(ar, &output)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", errors.New("Could not run script: " + strconv.Itoa(resp.StatusCode))
+	}
+	printed, scriptErr := parseScriptOutput(output)
+	if scriptErr != nil {
+		return printed, scriptErr
+	}
+	return output, nil
+}
+
+// RunScript runs a Groovy script in the script console of the controller and returns what it printed.
+// When the script throws, the output printed before is returned with a *ScriptError.
+// The caller needs the Overall/Administer permission.
+// Example: out, err := jenkins.RunScript(ctx, `println(Jenkins.instance.numExecutors)`)
+func (c *Client) RunScript(ctx context.Context, script string) (string, error) {
+	return c.runScript(ctx, "/scriptText", script)
+}
+
+// RunScript runs a Groovy script on the agent, see Client.RunScript.
+func (n *Node) RunScript(ctx context.Context, script string) (string, error) {
+	return n.Client.runScript(ctx, n.Base+"/scriptText", script)
+}
+
+const scriptResultMarker = "<<gojenkins-json>>"
+
+// groovyString quotes s as a Groovy string literal without interpolation.
+func groovyString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`).Replace(s) + "'"
+}
+
+// jsonScript wraps a script so that its return value is printed as JSON after a marker.
+// The script is evaluated as a script of its own, so that it can declare imports and methods,
+// by a shell sharing the binding and the default imports of the script console
+// (hudson.util.RemotingDiagnostics).
+func jsonScript(script string) string {
+	return "def __imports = new org.codehaus.groovy.control.customizers.ImportCustomizer()\n" +
+		"__imports.addStarImports('jenkins', 'jenkins.model', 'hudson', 'hudson.model')\n" +
+		"def __config = new org.codehaus.groovy.control.CompilerConfiguration()\n" +
+		"__config.addCompilationCustomizers(__imports)\n" +
+		"def __result = new GroovyShell(this.class.classLoader, binding, __config).evaluate(" + groovyString(script) + ")\n" +
+		"println('" + scriptResultMarker + "' + groovy.json.JsonOutput.toJson(__result))\n"
+}
+
+func decodeScriptResult(output string, v interface{}) error {
+	i := strings.LastIndex(output, scriptResultMarker)
+	if i < 0 {
+		return errors.New("script printed no result")
+	}
+	return json.Unmarshal([]byte(strings.TrimSpace(output[i+len(scriptResultMarker):])), v)
+}
+
+// RunScriptJSON runs a script returning a value, converts the value to JSON with
+// groovy.json.JsonOutput and decodes it into v.
+// Example: var names []string; jenkins.RunScriptJSON(ctx, `return Jenkins.instance.nodes*.nodeName`, &names)
+func (c *Client) RunScriptJSON(ctx context.Context, script string, v interface{}) error {
+	output, err := c.RunScript(ctx, jsonScript(script))
+	if err != nil {
+		return err
+	}
+	return decodeScriptResult(output, v)
+}
+
+// RunScriptJSON runs a script returning a value on the agent, see Client.RunScriptJSON.
+func (n *Node) RunScriptJSON(ctx context.Context, script string, v interface{}) error {
+	output, err := n.RunScript(ctx, jsonScript(script))
+	if err != nil {
+		return err
+	}
+	return decodeScriptResult(output, v)
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TreeField is a part of a tree query, created with Fields or Tree.
+// Tree queries make Jenkins return only the selected fields instead of the full
+// default JSON: https://wiki.jenkins.io/display/JENKINS/Remote+access+API
+// They are accepted by the PollTree methods and by some listing helpers, e.g. GetAllNodes.
+// Fields that are not selected are left as they were, so a PollTree after a Poll only
+// refreshes the selected fields.
+type TreeField interface {
+	String() string
+}
+
+type treeFields []string
+
+func (f treeFields) String() string {
+	return strings.Join(f, ",")
+}
+
+// Fields selects plain fields, e.g. Fields("name", "color").
+func Fields(names ...string) TreeField {
+	return treeFields(names)
+}
+
+// TreeNode selects fields of a nested object or list.
+type TreeNode struct {
+	name     string
+	children []TreeField
+	span     string
+}
+
+// Tree selects the children of the named field,
+// e.g. Tree("jobs", Fields("name"), Tree("lastBuild", Fields("number", "result")))
+// is the query jobs[name,lastBuild[number,result]].
+func Tree(name string, children ...TreeField) *TreeNode {
+	return &TreeNode{name: name, children: children}
+}
+
+// Range limits a list to the elements from (inclusive) to to (exclusive), e.g. {0,50}.
+// A negative to leaves the range open ended: {from,}.
+func (t *TreeNode) Range(from, to int) *TreeNode {
+	end := ""
+	if to >= 0 {
+		end = strconv.Itoa(to)
+	}
+	t.span = "{" + strconv.Itoa(from) + "," + end + "}"
+	return t
+}
+
+func (t *TreeNode) String() string {
+	s := t.name
+	if len(t.children) > 0 {
+		s += "[" + TreeQuery(t.children...) + "]"
+	}
+	return s + t.span
+}
+
+// TreeQuery joins the fields into the value of the tree query parameter.
+func TreeQuery(tree ...TreeField) string {
+	parts := make([]string, 0, len(tree))
+	for _, t := range tree {
+		if s := t.String(); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// treeQueryString returns the query string for a Poll, nil when no fields were selected.
+func treeQueryString(tree []TreeField) map[string]string {
+	if len(tree) == 0 {
+		return nil
+	}
+	return map[string]string{"tree": TreeQuery(tree...)}
+}
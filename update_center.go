@@ -0,0 +1,295 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+)
+
+// UpdateSite is an update center configured in Jenkins, the default one has the id "default".
+type UpdateSite struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	DataTimestamp int64  `json:"dataTimestamp"`
+	HasUpdates    bool   `json:"hasUpdates"`
+	// Updates are the newer versions of installed plugins.
+	Updates []UpdateSitePlugin `json:"updates"`
+}
+
+// UpdateSitePlugin is a plugin version offered by an update site.
+type UpdateSitePlugin struct {
+	Name                 string            `json:"name"`
+	Title                string            `json:"title"`
+	Version              string            `json:"version"`
+	RequiredCore         string            `json:"requiredCore"`
+	URL                  string            `json:"url"`
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+}
+
+// GetUpdateSites returns the update sites with the plugin updates Jenkins knows of.
+// Jenkins refreshes the update site data about once a day.
+func (c *Client) GetUpdateSites() ([]UpdateSite, error) {
+	var resp struct {
+		Sites []UpdateSite `json:"sites"`
+	}
+	tree := []TreeField{Tree("sites", Fields("id", "url", "dataTimestamp", "hasUpdates"),
+		Tree("updates", Fields("name", "title", "version", "requiredCore", "url", "dependencies")))}
+	r, err := c.Requester.GetJSON("/updateCenter", &resp, treeQueryString(tree))
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(r.StatusCode))
+	}
+	return resp.Sites, nil
+}
+
+// UpdateCenterMetadata is the update-center.json published by an update site.
+type UpdateCenterMetadata struct {
+	ID   string `json:"id"`
+	Core struct {
+		Version string `json:"version"`
+		URL     string `json:"url"`
+	} `json:"core"`
+	Plugins  map[string]UpdateCenterPlugin `json:"plugins"`
+	Warnings []SecurityWarning             `json:"warnings"`
+}
+
+type UpdateCenterPlugin struct {
+	Name             string                   `json:"name"`
+	Title            string                   `json:"title"`
+	Version          string                   `json:"version"`
+	RequiredCore     string                   `json:"requiredCore"`
+	URL              string                   `json:"url"`
+	ReleaseTimestamp string                   `json:"releaseTimestamp"`
+	Dependencies     []UpdateCenterDependency `json:"dependencies"`
+}
+
+type UpdateCenterDependency struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Optional bool   `json:"optional"`
+}
+
+// SecurityWarning is a published vulnerability of a plugin or of the core (Type "core").
+type SecurityWarning struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Message  string `json:"message"`
+	URL      string `json:"url"`
+	Versions []struct {
+		LastVersion string `json:"lastVersion"`
+		Pattern     string `json:"pattern"`
+	} `json:"versions"`
+}
+
+// Affects tells whether the version is vulnerable, i.e. matches one of the version patterns.
+// The patterns are Java regular expressions; when one of them is not valid in Go and no other
+// pattern matches, whether the version is affected is unknown and the error is returned.
+func (w SecurityWarning) Affects(version string) (bool, error) {
+	var invalid error
+	for _, v := range w.Versions {
+		re, err := regexp.Compile(`^(?:` + v.Pattern + `)$`)
+		if err != nil {
+			invalid = fmt.Errorf("warning %s: %v", w.ID, err)
+			continue
+		}
+		if re.MatchString(version) {
+			return true, nil
+		}
+	}
+	return false, invalid
+}
+
+// parseUpdateCenterJSON accepts update-center.json, which is wrapped in a JSONP
+// call updateCenter.post(...), and the plain update-center.actual.json.
+func parseUpdateCenterJSON(data []byte) (*UpdateCenterMetadata, error) {
+	data = bytes.TrimSpace(data)
+	if i := bytes.IndexByte(data, '('); i >= 0 && !bytes.HasPrefix(data, []byte("{")) {
+		data = bytes.TrimSuffix(bytes.TrimSuffix(data[i+1:], []byte(";")), []byte(")"))
+	}
+	metadata := new(UpdateCenterMetadata)
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// GetUpdateCenterMetadata downloads the metadata of an update site, an empty siteURL
+// selects the site Jenkins uses by default.
+func (c *Client) GetUpdateCenterMetadata(siteURL string) (*UpdateCenterMetadata, error) {
+	if siteURL == "" {
+		sites, err := c.GetUpdateSites()
+		if err != nil {
+			return nil, err
+		}
+		if len(sites) == 0 {
+			return nil, errors.New("Jenkins has no update site")
+		}
+		siteURL = sites[0].URL
+		for _, s := range sites {
+			if s.ID == "default" {
+				siteURL = s.URL
+				break
+			}
+		}
+	}
+	resp, err := c.Requester.Client.Get(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("Could not download " + siteURL + ": " + strconv.Itoa(resp.StatusCode))
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseUpdateCenterJSON(data)
+}
+
+// PluginUpdateStatus compares an installed plugin with the update site.
+type PluginUpdateStatus struct {
+	Name      string
+	Installed string
+	// Available is the latest version of the update site, empty if the site does not know the plugin.
+	Available    string
+	RequiredCore string
+	// CoreCompatible tells whether the available version runs on the installed core.
+	CoreCompatible bool
+	// Warnings are the security warnings affecting the installed version.
+	Warnings []SecurityWarning
+}
+
+func (s PluginUpdateStatus) HasUpdate() bool {
+	return s.Available != "" && CompareVersions(s.Available, s.Installed) > 0
+}
+
+func (s PluginUpdateStatus) Vulnerable() bool {
+	return len(s.Warnings) > 0
+}
+
+// FixedByUpdate tells whether the available version is not affected by the warnings.
+func (s PluginUpdateStatus) FixedByUpdate() bool {
+	if !s.HasUpdate() {
+		return false
+	}
+	for _, w := range s.Warnings {
+		if affected, err := w.Affects(s.Available); affected || err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// PluginUpdateReport is the state of the installed plugins and core against an update site.
+type PluginUpdateReport struct {
+	Core          string
+	CoreAvailable string
+	CoreWarnings  []SecurityWarning
+	Plugins       []PluginUpdateStatus
+	// Errors are the warnings that could not be checked against the installed versions,
+	// because their version patterns are not valid Go regular expressions.
+	Errors []error
+}
+
+// Updates returns the plugins with a newer version.
+func (r *PluginUpdateReport) Updates() []PluginUpdateStatus {
+	return r.filter(PluginUpdateStatus.HasUpdate)
+}
+
+// Vulnerable returns the plugins whose installed version has known vulnerabilities.
+func (r *PluginUpdateReport) Vulnerable() []PluginUpdateStatus {
+	return r.filter(PluginUpdateStatus.Vulnerable)
+}
+
+func (r *PluginUpdateReport) filter(keep func(PluginUpdateStatus) bool) []PluginUpdateStatus {
+	plugins := make([]PluginUpdateStatus, 0)
+	for _, p := range r.Plugins {
+		if keep(p) {
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins
+}
+
+// NewPluginUpdateReport checks the installed plugins and core version against update site metadata.
+func NewPluginUpdateReport(core string, installed PluginSet, metadata *UpdateCenterMetadata) *PluginUpdateReport {
+	report := &PluginUpdateReport{Core: core, CoreAvailable: metadata.Core.Version, CoreWarnings: make([]SecurityWarning, 0)}
+	affects := func(w SecurityWarning, name, version string) bool {
+		affected, err := w.Affects(version)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("%s %s: %v", name, version, err))
+		}
+		return affected
+	}
+	warnings := make(map[string][]SecurityWarning)
+	for _, w := range metadata.Warnings {
+		switch {
+		case w.Type == "core" && affects(w, "core", core):
+			report.CoreWarnings = append(report.CoreWarnings, w)
+		case w.Type == "plugin":
+			warnings[w.Name] = append(warnings[w.Name], w)
+		}
+	}
+	report.Plugins = make([]PluginUpdateStatus, 0, len(installed))
+	for _, name := range installed.Names() {
+		status := PluginUpdateStatus{Name: name, Installed: installed[name], Warnings: make([]SecurityWarning, 0)}
+		if p, ok := metadata.Plugins[name]; ok {
+			status.Available = p.Version
+			status.RequiredCore = p.RequiredCore
+			status.CoreCompatible = core == "" || p.RequiredCore == "" || CompareVersions(core, p.RequiredCore) >= 0
+		}
+		for _, w := range warnings[name] {
+			if affects(w, name, status.Installed) {
+				status.Warnings = append(status.Warnings, w)
+			}
+		}
+		report.Plugins = append(report.Plugins, status)
+	}
+	return report
+}
+
+// GetPluginUpdateReport checks the installed plugins against the default update site:
+// available versions, the core they require and the security warnings of the installed versions.
+// Example: report, _ := jenkins.GetPluginUpdateReport(); for _, p := range report.Vulnerable() { ... }
+func (c *Client) GetPluginUpdateReport() (*PluginUpdateReport, error) {
+	installed, err := c.GetPluginSet()
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := c.GetUpdateCenterMetadata("")
+	if err != nil {
+		return nil, err
+	}
+	core := c.Version
+	if core == "" {
+		resp, err := c.Requester.GetJSON("/", new(struct{}), treeQueryString([]TreeField{Fields("mode")}))
+		if err != nil {
+			return nil, err
+		}
+		core = resp.Header.Get("X-Jenkins")
+	}
+	return NewPluginUpdateReport(core, installed, metadata), nil
+}
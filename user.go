@@ -0,0 +1,301 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type User struct {
+	Raw    *UserResponse
+	Client *Client
+	Base   string
+}
+
+type UserResponse struct {
+	Class       string         `json:"_class"`
+	ID          string         `json:"id"`
+	FullName    string         `json:"fullName"`
+	Description string         `json:"description"`
+	AbsoluteURL string         `json:"absoluteUrl"`
+	Property    []userProperty `json:"property"`
+}
+
+type userProperty struct {
+	Class string `json:"_class"`
+	// hudson.tasks.Mailer$UserProperty
+	Address string `json:"address"`
+}
+
+// WhoAmI is the identity the client is authenticated as.
+type WhoAmI struct {
+	Name          string   `json:"name"`
+	Anonymous     bool     `json:"anonymous"`
+	Authenticated bool     `json:"authenticated"`
+	Authorities   []string `json:"authorities"`
+	// User is nil for the anonymous user.
+	User *User `json:"-"`
+}
+
+// UserSummary is a user known to Jenkins, e.g. from a login or a commit.
+type UserSummary struct {
+	ID       string
+	FullName string
+	URL      string
+	// LastChange is the time of the last commit of the user, zero if unknown.
+	LastChange time.Time
+}
+
+const mailerUserProperty = "hudson.tasks.Mailer$UserProperty"
+
+var userFields = Fields("id", "fullName", "description", "absoluteUrl")
+
+func (u *User) GetID() string {
+	return u.Raw.ID
+}
+
+func (u *User) GetFullName() string {
+	return u.Raw.FullName
+}
+
+// GetEmail returns the e-mail address of the user, empty if none is configured.
+func (u *User) GetEmail() string {
+	for _, p := range u.Raw.Property {
+		if p.Class == mailerUserProperty {
+			return p.Address
+		}
+	}
+	return ""
+}
+
+func (u *User) Poll() (int, error) {
+	return u.PollTree(userFields, Tree("property", Fields("_class", "address")))
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (u *User) PollTree(tree ...TreeField) (int, error) {
+	response, err := u.Client.Requester.GetJSON(u.Base, u.Raw, treeQueryString(tree))
+	if err != nil {
+		return 0, err
+	}
+	return response.StatusCode, nil
+}
+
+func (c *Client) GetUser(id string) (*User, error) {
+	user := &User{Client: c, Raw: new(UserResponse), Base: "/user/" + url.PathEscape(id)}
+	status, err := user.Poll()
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, errors.New("No user " + id)
+	}
+	return user, nil
+}
+
+// WhoAmI returns the identity and authorities of the client, with the user details
+// unless the client is anonymous.
+func (c *Client) WhoAmI() (*WhoAmI, error) {
+	who := new(WhoAmI)
+	resp, err := c.Requester.GetJSON("/whoAmI", who, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	if who.Anonymous || !who.Authenticated {
+		return who, nil
+	}
+	me := &User{Client: c, Raw: new(UserResponse), Base: "/me"}
+	status, err := me.Poll()
+	if err != nil {
+		return nil, err
+	}
+	if status == 200 {
+		me.Base = "/user/" + url.PathEscape(me.Raw.ID)
+		who.User = me
+	}
+	return who, nil
+}
+
+// GetUsers returns all users Jenkins knows of, computed by /asynchPeople.
+// On large instances Jenkins may need a moment to compute the list, until then it returns the users found so far.
+func (c *Client) GetUsers() ([]UserSummary, error) {
+	var resp struct {
+		Users []struct {
+			LastChange *int64 `json:"lastChange"`
+			User       struct {
+				ID          string `json:"id"`
+				FullName    string `json:"fullName"`
+				AbsoluteURL string `json:"absoluteUrl"`
+			} `json:"user"`
+		} `json:"users"`
+	}
+	tree := []TreeField{Tree("users", Fields("lastChange"), Tree("user", Fields("id", "fullName", "absoluteUrl")))}
+	r, err := c.Requester.GetJSON("/asynchPeople", &resp, treeQueryString(tree))
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, errors.New(strconv.Itoa(r.StatusCode))
+	}
+	users := make([]UserSummary, len(resp.Users))
+	for i, u := range resp.Users {
+		users[i] = UserSummary{ID: u.User.ID, FullName: u.User.FullName, URL: u.User.AbsoluteURL}
+		if u.LastChange != nil {
+			users[i].LastChange = time.Unix(0, *u.LastChange*int64(time.Millisecond))
+		}
+	}
+	return users, nil
+}
+
+// APIToken is an API token of a user. Value is only known when the token is generated,
+// Jenkins cannot show it later. String redacts the value.
+type APIToken struct {
+	UUID         string
+	Name         string
+	Value        string
+	CreationDate time.Time
+	LastUseDate  time.Time // zero if never used
+	UseCounter   int
+	Legacy       bool
+}
+
+func (t APIToken) String() string {
+	value := `""`
+	if t.Value != "" {
+		value = "<redacted>"
+	}
+	return fmt.Sprintf("APIToken{UUID: %q, Name: %q, Value: %s}", t.UUID, t.Name, value)
+}
+
+func (t APIToken) GoString() string {
+	return t.String()
+}
+
+func (u *User) apiTokenEndpoint(action string) string {
+	return u.Base + "/descriptorByName/jenkins.security.ApiTokenProperty/" + action
+}
+
+// GenerateAPIToken creates a new API token, its Value has to be stored by the caller.
+// Users can generate their own tokens, administrators those of other users only if
+// Jenkins allows it (jenkins.security.ApiTokenProperty.adminCanGenerateNewTokens).
+func (u *User) GenerateAPIToken(name string) (*APIToken, error) {
+	var resp struct {
+		Status string `json:"status"`
+		Data   struct {
+			TokenName  string `json:"tokenName"`
+			TokenUUID  string `json:"tokenUuid"`
+			TokenValue string `json:"tokenValue"`
+		} `json:"data"`
+	}
+	r, err := u.Client.Requester.Post(u.apiTokenEndpoint("generateNewToken"), nil, &resp, map[string]string{"newTokenName": name})
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 || resp.Status != "ok" {
+		return nil, fmt.Errorf("Could not generate API token %s for %s: %d", name, u.GetID(), r.StatusCode)
+	}
+	return &APIToken{UUID: resp.Data.TokenUUID, Name: resp.Data.TokenName, Value: resp.Data.TokenValue, CreationDate: time.Now()}, nil
+}
+
+// RevokeAPIToken revokes the token, requests using it fail from now on.
+func (u *User) RevokeAPIToken(uuid string) error {
+	r, err := u.Client.Requester.Post(u.apiTokenEndpoint("revoke"), nil, nil, map[string]string{"tokenUuid": uuid})
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != 200 {
+		return fmt.Errorf("Could not revoke API token %s of %s: %d", uuid, u.GetID(), r.StatusCode)
+	}
+	return nil
+}
+
+// ListAPITokens returns the API tokens of the user without their values.
+// Jenkins has no REST endpoint listing tokens, so they are read with the script console,
+// which needs the Overall/Administer permission.
+func (u *User) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	script := `def user = hudson.model.User.getById(` + groovyString(u.GetID()) + `, false)
+if (user == null) throw new IllegalArgumentException('No user ' + ` + groovyString(u.GetID()) + `)
+def property = user.getProperty(jenkins.security.ApiTokenProperty)
+if (property == null) return []
+return property.tokenList.collect { [uuid: it.uuid, name: it.name, creationDate: it.creationDate?.time,
+    lastUseDate: it.lastUseDate?.time, useCounter: it.useCounter, legacy: it.isLegacy] }`
+	var tokens []struct {
+		UUID         string `json:"uuid"`
+		Name         string `json:"name"`
+		CreationDate *int64 `json:"creationDate"`
+		LastUseDate  *int64 `json:"lastUseDate"`
+		UseCounter   int    `json:"useCounter"`
+		Legacy       bool   `json:"legacy"`
+	}
+	if err := u.Client.RunScriptJSON(ctx, script, &tokens); err != nil {
+		return nil, err
+	}
+	result := make([]APIToken, len(tokens))
+	for i, t := range tokens {
+		result[i] = APIToken{UUID: t.UUID, Name: t.Name, UseCounter: t.UseCounter, Legacy: t.Legacy}
+		if t.CreationDate != nil {
+			result[i].CreationDate = time.Unix(0, *t.CreationDate*int64(time.Millisecond))
+		}
+		if t.LastUseDate != nil {
+			result[i].LastUseDate = time.Unix(0, *t.LastUseDate*int64(time.Millisecond))
+		}
+	}
+	return result, nil
+}
+
+// RotateAPIToken generates a new token and revokes the other tokens with the same name.
+// The new token is returned even if revoking an old one failed.
+// The tokens are found with ListAPITokens, so the caller needs the Overall/Administer permission;
+// use ReplaceAPIToken to rotate a token whose UUID is known without it.
+func (u *User) RotateAPIToken(ctx context.Context, name string) (*APIToken, error) {
+	tokens, err := u.ListAPITokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := u.GenerateAPIToken(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		if t.Name == name && t.UUID != token.UUID {
+			if err := u.RevokeAPIToken(t.UUID); err != nil {
+				return token, err
+			}
+		}
+	}
+	return token, nil
+}
+
+// ReplaceAPIToken generates a new token and revokes the token with the UUID oldUUID,
+// e.g. for a service account rotating its own token. The new token is returned even if
+// revoking the old one failed.
+func (u *User) ReplaceAPIToken(oldUUID string, name string) (*APIToken, error) {
+	token, err := u.GenerateAPIToken(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.RevokeAPIToken(oldUUID); err != nil {
+		return token, err
+	}
+	return token, nil
+}
@@ -14,7 +14,14 @@
 
 package gojenkins
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"time"
+)
 
 func makeJson(data interface{}) string {
 	str, err := json.Marshal(data)
@@ -23,3 +30,57 @@ func makeJson(data interface{}) string {
 	}
 	return string(json.RawMessage(str))
 }
+
+// unmarshalXML decodes a Jenkins config.xml. Jenkins writes XML 1.1 declarations,
+// which encoding/xml refuses, so the declaration is dropped first.
+func unmarshalXML(data string, v interface{}) error {
+	data = strings.TrimSpace(data)
+	if strings.HasPrefix(data, "<?xml") {
+		if end := strings.Index(data, "?>"); end >= 0 {
+			data = data[end+2:]
+		}
+	}
+	return xml.Unmarshal([]byte(data), v)
+}
+
+// pollInterval returns Client.PollInterval, or def when it is not set.
+func (c *Client) pollInterval(def time.Duration) time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return def
+}
+
+// pollUntil calls done every interval until it reports true, returns an error or ctx is done.
+func pollUntil(ctx context.Context, interval time.Duration, done func() (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// endpointOf turns an absolute URL returned by Jenkins into an endpoint for the Requester,
+// taking into account Jenkins running under a path prefix such as /jenkins.
+func (c *Client) endpointOf(absoluteURL string) string {
+	u, err := url.Parse(absoluteURL)
+	if err != nil {
+		return absoluteURL
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if base, err := url.Parse(c.Requester.Base); err == nil {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(base.Path, "/"))
+	}
+	return path
+}
@@ -0,0 +1,259 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Columns of a LIST_VIEW.
+var (
+	STATUS_COLUMN        = "hudson.views.StatusColumn"
+	WEATHER_COLUMN       = "hudson.views.WeatherColumn"
+	JOB_COLUMN           = "hudson.views.JobColumn"
+	LAST_SUCCESS_COLUMN  = "hudson.views.LastSuccessColumn"
+	LAST_FAILURE_COLUMN  = "hudson.views.LastFailureColumn"
+	LAST_DURATION_COLUMN = "hudson.views.LastDurationColumn"
+	BUILD_BUTTON_COLUMN  = "hudson.views.BuildButtonColumn"
+
+	// DEFAULT_LIST_VIEW_COLUMNS are the columns of a new LIST_VIEW.
+	DEFAULT_LIST_VIEW_COLUMNS = []string{STATUS_COLUMN, WEATHER_COLUMN, JOB_COLUMN,
+		LAST_SUCCESS_COLUMN, LAST_FAILURE_COLUMN, LAST_DURATION_COLUMN, BUILD_BUTTON_COLUMN}
+)
+
+// ownerBase is the endpoint of the view group holding the view.
+func (v *View) ownerBase() string {
+	return v.Base[:strings.LastIndex(v.Base, "/view/")]
+}
+
+func (v *View) GetConfig() (string, error) {
+	var data string
+	_, err := v.Client.Requester.GetXML(v.Base+"/config.xml", &data, nil)
+	if err != nil {
+		return "", err
+	}
+	return data, nil
+}
+
+// UpdateConfig replaces the configuration of the view. The name in the config is ignored, use Rename.
+func (v *View) UpdateConfig(config string) error {
+	resp, err := v.Client.Requester.PostXML(v.Base+"/config.xml", config, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == 200 {
+		v.Poll()
+		return nil
+	}
+	return errors.New(strconv.Itoa(resp.StatusCode))
+}
+
+// Delete removes the view, its jobs are kept. The primary view cannot be deleted.
+func (v *View) Delete() (bool, error) {
+	resp, err := v.Client.Requester.Post(v.Base+"/doDelete", nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != 200 {
+		return false, errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	return true, nil
+}
+
+// Rename renames the view. Jenkins ignores names in config.xml, so the view is
+// copied to the new name with its configuration and the old view is deleted.
+// If the old view cannot be deleted, e.g. because it is the primary view, the copy
+// is deleted again and the view keeps its name.
+func (v *View) Rename(name string) error {
+	config, err := v.GetConfig()
+	if err != nil {
+		return err
+	}
+	owner := v.ownerBase()
+	base := owner + "/view/" + url.PathEscape(name)
+	resp, err := v.Client.Requester.PostXML(owner+"/createView", config, nil, map[string]string{"name": name})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return errors.New("Could not create view " + name + ": " + strconv.Itoa(resp.StatusCode))
+	}
+	if _, err := v.Delete(); err != nil {
+		created := &View{Client: v.Client, Raw: new(ViewResponse), Base: base}
+		if _, undoErr := created.Delete(); undoErr != nil {
+			return fmt.Errorf("Could not delete view %s: %v, its copy %s is left: %v", v.GetName(), err, name, undoErr)
+		}
+		return err
+	}
+	v.Base = base
+	_, err = v.Poll()
+	return err
+}
+
+// getListViewConfig returns the decoded config of a LIST_VIEW.
+func (v *View) getListViewConfig() (*xmlConfig, error) {
+	data, err := v.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	config := new(xmlConfig)
+	if err := unmarshalXML(data, config); err != nil {
+		return nil, err
+	}
+	if config.XMLName.Local != LIST_VIEW {
+		return nil, errors.New("View " + v.GetName() + " is no list view but " + config.XMLName.Local)
+	}
+	return config, nil
+}
+
+func (v *View) updateXMLConfig(config *xmlConfig) error {
+	data, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return v.UpdateConfig(string(data))
+}
+
+// SetIncludeRegex sets the regular expression selecting the jobs of a LIST_VIEW,
+// an empty regex only keeps the jobs added by name.
+func (v *View) SetIncludeRegex(regex string) error {
+	config, err := v.getListViewConfig()
+	if err != nil {
+		return err
+	}
+	elements := make([]xmlElement, 0, len(config.Elements)+1)
+	for _, e := range config.Elements {
+		if e.XMLName.Local != "includeRegex" {
+			elements = append(elements, e)
+		}
+	}
+	if regex != "" {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(regex))
+		elements = append(elements, xmlElement{XMLName: xml.Name{Local: "includeRegex"}, Inner: b.String()})
+	}
+	config.Elements = elements
+	return v.updateXMLConfig(config)
+}
+
+// GetColumns returns the column classes of a LIST_VIEW, e.g. STATUS_COLUMN.
+func (v *View) GetColumns() ([]string, error) {
+	config, err := v.getListViewConfig()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0)
+	for _, e := range config.Elements {
+		if e.XMLName.Local != "columns" {
+			continue
+		}
+		var items xmlConfig
+		if err := xml.Unmarshal([]byte("<columns>"+e.Inner+"</columns>"), &items); err != nil {
+			return nil, err
+		}
+		for _, c := range items.Elements {
+			columns = append(columns, c.XMLName.Local)
+		}
+	}
+	return columns, nil
+}
+
+// SetColumns replaces the columns of a LIST_VIEW. Columns the view already has keep their settings.
+// Example: view.SetColumns(append(gojenkins.DEFAULT_LIST_VIEW_COLUMNS, "jenkins.branch.DescriptionColumn")...)
+func (v *View) SetColumns(columns ...string) error {
+	config, err := v.getListViewConfig()
+	if err != nil {
+		return err
+	}
+	index := -1
+	existing := make(map[string]xmlElement)
+	for i, e := range config.Elements {
+		if e.XMLName.Local != "columns" {
+			continue
+		}
+		index = i
+		var items xmlConfig
+		if err := xml.Unmarshal([]byte("<columns>"+e.Inner+"</columns>"), &items); err != nil {
+			return err
+		}
+		for _, c := range items.Elements {
+			existing[c.XMLName.Local] = c
+		}
+	}
+	if index < 0 {
+		config.Elements = append(config.Elements, xmlElement{XMLName: xml.Name{Local: "columns"}})
+		index = len(config.Elements) - 1
+	}
+	var inner bytes.Buffer
+	for _, c := range columns {
+		item, ok := existing[c]
+		if !ok {
+			item = xmlElement{XMLName: xml.Name{Local: c}}
+		}
+		if err := xml.NewEncoder(&inner).Encode(item); err != nil {
+			return err
+		}
+	}
+	config.Elements[index].Inner = inner.String()
+	return v.updateXMLConfig(config)
+}
+
+// GetViews returns the children of a NESTED_VIEW.
+func (v *View) GetViews() []ViewData {
+	return v.Raw.Views
+}
+
+// GetView returns a child of a NESTED_VIEW.
+func (v *View) GetView(name string, tree ...TreeField) (*View, error) {
+	return v.Client.getView(v.Base, name, tree...)
+}
+
+// CreateView creates a child of a NESTED_VIEW.
+func (v *View) CreateView(name string, viewType string) (*View, error) {
+	return v.Client.createView(v.Base, name, viewType)
+}
+
+// GetViews returns the views defined in the folder.
+func (f *Folder) GetViews() []ViewData {
+	return f.Raw.Views
+}
+
+// GetView returns a view defined in the folder.
+func (f *Folder) GetView(name string, tree ...TreeField) (*View, error) {
+	return f.Client.getView(f.Base, name, tree...)
+}
+
+// CreateView creates a view in the folder.
+func (f *Folder) CreateView(name string, viewType string) (*View, error) {
+	return f.Client.createView(f.Base, name, viewType)
+}
+
+func (c *Client) getView(ownerBase string, name string, tree ...TreeField) (*View, error) {
+	view := &View{Client: c, Raw: new(ViewResponse), Base: ownerBase + "/view/" + url.PathEscape(name)}
+	status, err := view.PollTree(tree...)
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, errors.New("No view " + name + ": " + strconv.Itoa(status))
+	}
+	return view, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2015 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gojenkins
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// FOLDER is the class of folders of the CloudBees Folders plugin.
+var FOLDER = "com.cloudbees.hudson.plugins.folder.Folder"
+
+// Kinds of ViewTreeNode.
+const (
+	VIEW_TREE_ROOT   = "root"
+	VIEW_TREE_FOLDER = "folder"
+	VIEW_TREE_VIEW   = "view"
+)
+
+// ViewTreeNode is Jenkins itself, a folder or a view in the tree returned by GetViewTree.
+// Folders are all jobs holding views, e.g. FOLDER, MULTIBRANCH_PROJECT or ORGANIZATION_FOLDER.
+// Children of the root and of folders are their views and sub folders, children of a
+// NESTED_VIEW are its views. Err is set when the node could not be fetched, its
+// children are then unknown.
+type ViewTreeNode struct {
+	Kind     string
+	Name     string
+	Class    string
+	Base     string
+	Jobs     []InnerJob
+	Children []*ViewTreeNode
+	Err      error
+}
+
+// View returns the view of a VIEW_TREE_VIEW node.
+func (n *ViewTreeNode) View(c *Client) *View {
+	return &View{Client: c, Raw: &ViewResponse{Name: n.Name, Class: n.Class, Jobs: n.Jobs}, Base: n.Base}
+}
+
+// Walk calls fn for the node and all its descendants, depth first, with the depth of each node.
+func (n *ViewTreeNode) Walk(fn func(node *ViewTreeNode, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *ViewTreeNode) walk(fn func(node *ViewTreeNode, depth int), depth int) {
+	fn(n, depth)
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Failed returns the nodes that could not be fetched.
+func (n *ViewTreeNode) Failed() []*ViewTreeNode {
+	failed := make([]*ViewTreeNode, 0)
+	n.Walk(func(node *ViewTreeNode, depth int) {
+		if node.Err != nil {
+			failed = append(failed, node)
+		}
+	})
+	return failed
+}
+
+type viewTreeResponse struct {
+	Class string `json:"_class"`
+	Name  string `json:"name"`
+	Jobs  []struct {
+		InnerJob
+		// Views is only returned for folders, which are view groups.
+		Views []ViewData `json:"views"`
+	} `json:"jobs"`
+	Views []ViewData `json:"views"`
+}
+
+var viewTreeFields = []TreeField{
+	Fields("_class", "name"),
+	Tree("jobs", Fields("_class", "name", "url", "color"), Tree("views", Fields("name"))),
+	Tree("views", Fields("_class", "name", "url")),
+}
+
+func (c *Client) fetchViewTreeNode(n *ViewTreeNode) error {
+	resp := new(viewTreeResponse)
+	r, err := c.Requester.GetJSON(n.Base+"/", resp, treeQueryString(viewTreeFields))
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != 200 {
+		return errors.New(strconv.Itoa(r.StatusCode))
+	}
+	if n.Kind != VIEW_TREE_ROOT {
+		n.Class = resp.Class
+	}
+	n.Jobs = make([]InnerJob, len(resp.Jobs))
+	for i, j := range resp.Jobs {
+		n.Jobs[i] = j.InnerJob
+	}
+	// Only nested views have views, the views of other views would be their owner's.
+	if n.Kind != VIEW_TREE_VIEW || n.Class == NESTED_VIEW {
+		for _, v := range resp.Views {
+			n.Children = append(n.Children, &ViewTreeNode{Kind: VIEW_TREE_VIEW, Name: v.Name, Class: v.Class, Base: n.Base + "/view/" + url.PathEscape(v.Name)})
+		}
+	}
+	if n.Kind != VIEW_TREE_VIEW {
+		for _, j := range resp.Jobs {
+			if j.Views != nil {
+				n.Children = append(n.Children, &ViewTreeNode{Kind: VIEW_TREE_FOLDER, Name: j.Name, Class: j.Class, Base: n.Base + "/job/" + url.PathEscape(j.Name)})
+			}
+		}
+	}
+	return nil
+}
+
+// GetViewTree walks all views and folders: top-level views, views of nested views and the
+// views and sub folders of every folder, with the jobs of each. The tree is fetched level by
+// level with up to Client.Concurrency parallel requests. Nodes that could not be fetched
+// have their Err set, the rest of the tree is still returned.
+// Example: tree, _ := jenkins.GetViewTree(); tree.Walk(func(n *gojenkins.ViewTreeNode, depth int) { ... })
+func (c *Client) GetViewTree() (*ViewTreeNode, error) {
+	root := &ViewTreeNode{Kind: VIEW_TREE_ROOT, Name: "Jenkins"}
+	if err := c.fetchViewTreeNode(root); err != nil {
+		return nil, err
+	}
+	level := root.Children
+	for len(level) > 0 {
+		names := make([]string, len(level))
+		for i, n := range level {
+			names[i] = n.Base
+		}
+		c.fetchAll(names, func(i int) error {
+			level[i].Err = c.fetchViewTreeNode(level[i])
+			return level[i].Err
+		})
+		next := make([]*ViewTreeNode, 0)
+		for _, n := range level {
+			next = append(next, n.Children...)
+		}
+		level = next
+	}
+	return root, nil
+}
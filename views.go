@@ -16,6 +16,7 @@ package gojenkins
 
 import (
 	"errors"
+	"net/url"
 	"strconv"
 )
 
@@ -26,11 +27,14 @@ type View struct {
 }
 
 type ViewResponse struct {
+	Class       string        `json:"_class"`
 	Description string        `json:"description"`
 	Jobs        []InnerJob    `json:"jobs"`
 	Name        string        `json:"name"`
 	Property    []interface{} `json:"property"`
 	URL         string        `json:"url"`
+	// Views are the children of a NESTED_VIEW.
+	Views []ViewData `json:"views"`
 }
 
 var (
@@ -86,33 +90,47 @@ func (v *View) GetUrl() string {
 }
 
 func (v *View) Poll() (int, error) {
-	response, err := v.Client.Requester.GetJSON(v.Base, v.Raw, nil)
+	return v.PollTree()
+}
+
+// PollTree polls only the selected fields, see TreeField.
+func (v *View) PollTree(tree ...TreeField) (int, error) {
+	response, err := v.Client.Requester.GetJSON(v.Base, v.Raw, treeQueryString(tree))
 	if err != nil {
 		return 0, err
 	}
 	return response.StatusCode, nil
 }
 
-func (c *Client) GetView(name string) (*View, error) {
+// Get a top-level view, optionally with only the selected tree fields.
+func (c *Client) GetView(name string, tree ...TreeField) (*View, error) {
 	url := "/view/" + name
 	view := View{Client: c, Raw: new(ViewResponse), Base: url}
-	_, err := view.Poll()
+	_, err := view.PollTree(tree...)
 	if err != nil {
 		return nil, err
 	}
 	return &view, nil
 }
 
+// Get all top-level views, using up to Client.Concurrency parallel requests.
+// Views that could not be fetched are nil and reported in the returned *BulkError.
 func (c *Client) GetAllViews() ([]*View, error) {
 	_, err := c.Poll()
 	if err != nil {
 		return nil, err
 	}
-	views := make([]*View, len(c.Raw.Views))
+	names := make([]string, len(c.Raw.Views))
 	for i, v := range c.Raw.Views {
-		views[i], _ = c.GetView(v.Name)
+		names[i] = v.Name
 	}
-	return views, nil
+	views := make([]*View, len(names))
+	err = c.fetchAll(names, func(i int) error {
+		v, err := c.GetView(names[i])
+		views[i] = v
+		return err
+	})
+	return views, err
 }
 
 // Create View
@@ -126,8 +144,13 @@ func (c *Client) GetAllViews() ([]*View, error) {
 // 		gojenkins.PIPELINE_VIEW
 // Example: jenkins.CreateView("newView",gojenkins.LIST_VIEW)
 func (c *Client) CreateView(name string, viewType string) (*View, error) {
-	view := &View{Client: c, Raw: new(ViewResponse), Base: "/view/" + name}
-	endpoint := "/createView"
+	return c.createView("", name, viewType)
+}
+
+// createView creates a view in the view group at ownerBase: Jenkins, a folder or a nested view.
+func (c *Client) createView(ownerBase string, name string, viewType string) (*View, error) {
+	view := &View{Client: c, Raw: new(ViewResponse), Base: ownerBase + "/view/" + url.PathEscape(name)}
+	endpoint := ownerBase + "/createView"
 	data := map[string]string{
 		"name":   name,
 		"mode":   viewType,
@@ -144,7 +167,11 @@ func (c *Client) CreateView(name string, viewType string) (*View, error) {
 	}
 
 	if r.StatusCode == 200 {
-		return c.GetView(name)
+		_, err := view.Poll()
+		if err != nil {
+			return nil, err
+		}
+		return view, nil
 	}
 	return nil, errors.New(strconv.Itoa(r.StatusCode))
 }